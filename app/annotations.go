@@ -0,0 +1,109 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// annotation is a note attached to one log line of one of the files.
+// The line is found again by its offset, and the text of the line is
+// kept too so that reports still show it if the file changes.
+type annotation struct {
+	File   int    `json:"file"`   // The index of the file the line is in
+	Offset int64  `json:"offset"` // The offset of the line in the file
+	Time   int64  `json:"time"`   // The timestamp of the line, or of the line before it if it has none
+	Text   string `json:"text"`   // The note
+	Line   string `json:"line"`   // The log line
+}
+
+// lineTime returns the timestamp of the chunk, or of the closest
+// line before it with a timestamp if it has none.
+func lineTime(chunk *filechunk.FileChunk) int64 {
+	if chunk.LineTimeStamp > 1 {
+		return chunk.LineTimeStamp
+	}
+	if prevChunk := chunk.GetPrevTimestampedFileChunk(); prevChunk != nil {
+		return prevChunk.LineTimeStamp
+	}
+	return chunk.LineTimeStamp
+}
+
+// newAnnotation attaches the text to the current line of the file at index
+func newAnnotation(fileViews []fileView, index int, text string) *annotation {
+	chunk := fileViews[index].currChunk
+	return &annotation{
+		File:   index,
+		Offset: chunk.FileOffsetStart,
+		Time:   lineTime(chunk),
+		Text:   text,
+		Line:   string(bytes.TrimRight(chunk.FileChunkBytes, "\r\n")),
+	}
+}
+
+// parseAnnotateArgs splits the arguments of the annotate command into the
+// index of the file to annotate and the text. The file is given as "#N"
+// before the text, and otherwise the file that was stepped last is used,
+// or the one furthest ahead in time if none has been stepped.
+func parseAnnotateArgs(fileViews []fileView, args []string) (int, string, error) {
+	index := activeFileIndex(fileViews)
+	if strings.HasPrefix(args[0], "#") {
+		paneIndex, err := parsePaneNumber(strings.TrimPrefix(args[0], "#"), len(fileViews))
+		if err != nil {
+			return -1, "", err
+		}
+		index = paneIndex
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return -1, "", fmt.Errorf("the annotation needs some text")
+	}
+	return index, strings.Join(args, " "), nil
+}
+
+// sortAnnotations puts the annotations in time order across all the files
+func sortAnnotations(annotations []*annotation) {
+	sort.SliceStable(annotations, func(a, b int) bool {
+		return annotations[a].Time < annotations[b].Time
+	})
+}
+
+// annotationChunk finds the annotated line in its file
+func annotationChunk(fileViews []fileView, a *annotation) (*filechunk.FileChunk, error) {
+	if a.File < 0 || a.File >= len(fileViews) {
+		return nil, fmt.Errorf("the annotation is on file %d, but there are only %d files", a.File+1, len(fileViews))
+	}
+	return fileViews[a.File].currChunk.GetFileChunkAtOffset(a.Offset), nil
+}
+
+// describeAnnotations lists the annotations in time order, numbered
+// so that they can be jumped to and removed.
+func describeAnnotations(fileViews []fileView, annotations []*annotation) string {
+	if len(annotations) == 0 {
+		return "no annotations"
+	}
+	var parts []string
+	for i, a := range annotations {
+		fileName := strconv.Itoa(a.File + 1)
+		if a.File < len(fileViews) {
+			fileName = uniqueNames(logFilenames(fileViews))[a.File]
+		}
+		parts = append(parts, fmt.Sprintf("%d. %s %s: %s", i+1, time.Unix(0, a.Time).UTC().Format(markTimeFormat), fileName, a.Text))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// parseAnnotationNumber parses the number of an annotation as listed
+// by describeAnnotations and returns its index.
+func parseAnnotationNumber(arg string, annotations []*annotation) (int, error) {
+	number, err := strconv.Atoi(arg)
+	if err != nil || number < 1 || number > len(annotations) {
+		return -1, fmt.Errorf("bad annotation number %q, expected 1 to %d", arg, len(annotations))
+	}
+	return number - 1, nil
+}
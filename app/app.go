@@ -2,10 +2,14 @@
 package app
 
 import (
+	"fmt"
+	"io"
 	"log"
 	"math"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gdamore/tcell"
@@ -20,14 +24,25 @@ import (
 // and it is responsible for showing the appropriate section of the
 // file to the user.
 type fileView struct {
-	*tview.TextView                      // The TextView is the text box widget from rivo/tview
-	file            *os.File             // The file that this fileView is responsible for viewing
-	headChunk       *filechunk.FileChunk // The headChunk is stored to allow for easy jumping to head of file
-	tailChunk       *filechunk.FileChunk // The tailChunk is stored to allow for easy jumping to tail of file
-	currChunk       *filechunk.FileChunk // The currentChunk is the current chunk being viewed on the screen
-	index           int                  // This is the index of this fileView out of the list of all files being viewed
-	lastScrollTime  int64                // Stores the last time this file was scrolled. Used to break ties when the timestamps are the same
-	allFileViews    []fileView           // Stores a pointer to all the other fileViews including our own
+	*tview.TextView                        // The TextView is the text box widget from rivo/tview
+	file            *os.File               // The file that this fileView is responsible for viewing
+	headChunk       *filechunk.FileChunk   // The headChunk is stored to allow for easy jumping to head of file
+	tailChunk       *filechunk.FileChunk   // The tailChunk is stored to allow for easy jumping to tail of file
+	currChunk       *filechunk.FileChunk   // The currentChunk is the current chunk being viewed on the screen
+	index           int                    // This is the index of this fileView out of the list of all files being viewed
+	lastScrollTime  int64                  // Stores the last time this file was scrolled. Used to break ties when the timestamps are the same
+	moveOrder       int64                  // Counts up each time one of the fileViews moves, and is the count when this one moved last, or 0 if it has not
+	allFileViews    []fileView             // Stores a pointer to all the other fileViews including our own
+	displayChunks   []*filechunk.FileChunk // The chunks currently loaded into the TextView, in file order
+	lastWidth       int                    // The inner width of the pane the last time the display text was set
+	lastHeight      int                    // The inner height of the pane the last time the display text was set
+	logFilename     string                 // The name of the file as it was given on the command line
+	fileSize        int64                  // The size of the file, used to show how far through the file we are
+	lineCountOffset int64                  // The file offset up to which lineCountValue newlines were counted
+	lineCountValue  int64                  // The number of newlines before lineCountOffset
+	lineCounting    bool                   // Whether lines are being counted in the background
+	queueUpdateDraw func(func())           // Runs a function on the UI thread and redraws, or nil to count lines right away
+	restarts        []*restart             // Where the file restarts, in file order, filled in by a background scan
 }
 
 // AdvanceNextFileViewForward figures out which fileview is next
@@ -64,6 +79,7 @@ func AdvanceNextFileViewForward(fileViews []fileView) int {
 	if minIndex > -1 {
 		fileViews[minIndex].currChunk = currMinChunk
 		fileViews[minIndex].lastScrollTime = time.Now().Unix()
+		markMoved(fileViews, minIndex)
 	}
 	return minIndex
 }
@@ -102,6 +118,7 @@ func AdvancePrevFileViewBackward(fileViews []fileView) int {
 	if maxIndex > -1 {
 		fileViews[maxIndex].currChunk = currMaxChunk
 		fileViews[maxIndex].lastScrollTime = time.Now().Unix()
+		markMoved(fileViews, maxIndex)
 	}
 	return maxIndex
 }
@@ -130,7 +147,7 @@ func MoveAllToEnd(fileViews []fileView) {
 // the logs jump to that spot.
 func MoveAllToTime(fileViews []fileView, searchTime int64) {
 	for i := range fileViews {
-		closestChunk := fileViews[i].currChunk.GetFileChunkClosestToTime(searchTime)
+		closestChunk := fileViews[i].chunkClosestToTime(searchTime)
 		if closestChunk != nil {
 			fileViews[i].currChunk = closestChunk
 			fileViews[i].SetDisplayText()
@@ -138,28 +155,272 @@ func MoveAllToTime(fileViews []fileView, searchTime int64) {
 	}
 }
 
-// This updates the display for the fileView based on the currentChunk.
-// For now, we show the currentChunk highlighted and then the previous and
-// next chunks for context.
+// chunkClosestToTime returns the last timestamped log line of the file at
+// or before searchTime, or the first one if they are all after it, or nil if
+// the file has no timestamps. The line is found with a binary search over
+// the file, so a jump far away only reads the part of the file around it.
+func (fv *fileView) chunkClosestToTime(searchTime int64) *filechunk.FileChunk {
+	offset := filechunk.FindOffsetForTime(fv.file, searchTime+1)
+	chunk := fv.currChunk.GetFileChunkAtOffset(offset)
+	if offset > chunk.FileOffsetEnd && chunk.LineTimeStamp > 1 {
+		return chunk
+	}
+	if prevChunk := chunk.GetPrevTimestampedFileChunk(); prevChunk != nil {
+		return prevChunk
+	}
+	if chunk.LineTimeStamp > 1 {
+		return chunk
+	}
+	return chunk.GetNextTimestampedFileChunk()
+}
+
+// contextAbovePercent is the share of a pane's visible height that is used
+// for lines before the current line. The rest of the pane shows the lines
+// after it.
+var contextAbovePercent = 50
+
+// scrollbackPages is how many extra pages of lines are loaded above and below
+// the visible context so the user can scroll around within a pane without
+// changing the synced position.
+const scrollbackPages = 1
+
+// defaultPaneHeight is used before the first draw when the pane
+// does not know its size yet.
+const defaultPaneHeight = 3
+
+// rowsForLine estimates how many screen rows a log line takes up
+// once it is wrapped to the given width.
+func rowsForLine(line []byte, width int) int {
+	if width < 1 {
+		return 1
+	}
+	lineWidth := plainLineWidth(line)
+	if lineWidth == 0 {
+		return 1
+	}
+	return (lineWidth + width - 1) / width
+}
+
+// timeQuantum is how far in time the '[' and ']' keys step all the files
+var timeQuantum = time.Second
+
+// CurrentClusterTime returns the time of the cluster as a whole, which is
+// the latest timestamp of all the current log lines. Since stepping always
+// advances the file with the earliest next log line, this is the time
+// of the most recent log line we have stepped past.
+func CurrentClusterTime(fileViews []fileView) int64 {
+	var currTime int64 = -1
+	for i := range fileViews {
+		if fileViews[i].currChunk.LineTimeStamp > currTime {
+			currTime = fileViews[i].currChunk.LineTimeStamp
+		}
+	}
+	return currTime
+}
+
+// StepAllByDuration moves all the log lines to their last log line at or
+// before the current cluster time plus the given duration. A negative
+// duration steps backward in time. If every file is silent for longer than
+// the duration, so that stepping forward would not move any of them, the
+// files move to the next log line of any file instead.
+func StepAllByDuration(fileViews []fileView, d time.Duration) {
+	currTime := CurrentClusterTime(fileViews)
+	if currTime <= 1 {
+		return
+	}
+	chunks := currentChunks(fileViews)
+	MoveAllToTime(fileViews, currTime+int64(d))
+	if d <= 0 {
+		return
+	}
+	for i := range fileViews {
+		if fileViews[i].currChunk != chunks[i] {
+			return
+		}
+	}
+	if nextTime := nextTimeStamp(fileViews); nextTime > 1 {
+		MoveAllToTime(fileViews, nextTime)
+	}
+}
+
+// nextTimeStamp returns the earliest timestamp of the log lines after the
+// current log lines of the files, or -1 if they are all at their last
+// timestamped line.
+func nextTimeStamp(fileViews []fileView) int64 {
+	var nextTime int64 = -1
+	for i := range fileViews {
+		nextChunk := fileViews[i].currChunk.GetNextTimestampedFileChunk()
+		if nextChunk != nil && (nextTime < 0 || nextChunk.LineTimeStamp < nextTime) {
+			nextTime = nextChunk.LineTimeStamp
+		}
+	}
+	return nextTime
+}
+
+// headTimeStamp returns the timestamp of the first timestamped
+// log line in the file, or -1 if there is none.
+func (fv *fileView) headTimeStamp() int64 {
+	chunk := fv.headChunk
+	if chunk.LineTimeStamp <= 1 {
+		chunk = chunk.GetNextTimestampedFileChunk()
+	}
+	if chunk == nil {
+		return -1
+	}
+	return chunk.LineTimeStamp
+}
+
+// tailTimeStamp returns the timestamp of the last timestamped
+// log line in the file, or -1 if there is none.
+func (fv *fileView) tailTimeStamp() int64 {
+	chunk := fv.tailChunk
+	if chunk.LineTimeStamp <= 1 {
+		chunk = chunk.GetPrevTimestampedFileChunk()
+	}
+	if chunk == nil {
+		return -1
+	}
+	return chunk.LineTimeStamp
+}
+
+// GetTimeReference returns the times of the cluster that time input
+// in the command box can be relative to.
+func GetTimeReference(fileViews []fileView) TimeReference {
+	ref := TimeReference{
+		Current:      CurrentClusterTime(fileViews),
+		Start:        math.MaxInt64,
+		End:          -1,
+		OverlapStart: -1,
+		OverlapEnd:   math.MaxInt64,
+	}
+	for i := range fileViews {
+		headTime := fileViews[i].headTimeStamp()
+		tailTime := fileViews[i].tailTimeStamp()
+		if headTime <= 1 || tailTime <= 1 {
+			continue
+		}
+		if headTime < ref.Start {
+			ref.Start = headTime
+		}
+		if headTime > ref.OverlapStart {
+			ref.OverlapStart = headTime
+		}
+		if tailTime > ref.End {
+			ref.End = tailTime
+		}
+		if tailTime < ref.OverlapEnd {
+			ref.OverlapEnd = tailTime
+		}
+	}
+	return ref
+}
+
+// SyncAllToFileChunk makes the given chunk the current chunk of the
+// fileView at index and moves all the other fileViews to their closest
+// log line at the time of that chunk. This is used when the user selects
+// a line in one of the files. If the selected line has no timestamp,
+// the timestamp of the closest previous log line is used instead.
+func SyncAllToFileChunk(fileViews []fileView, index int, chunk *filechunk.FileChunk) {
+	fileViews[index].currChunk = chunk
+	fileViews[index].lastScrollTime = time.Now().Unix()
+	markMoved(fileViews, index)
+
+	syncTime := chunk.LineTimeStamp
+	if syncTime <= 1 {
+		if prevChunk := chunk.GetPrevTimestampedFileChunk(); prevChunk != nil {
+			syncTime = prevChunk.LineTimeStamp
+		}
+	}
+
+	for i := range fileViews {
+		if i != index && syncTime > 1 {
+			closestChunk := fileViews[i].chunkClosestToTime(syncTime)
+			if closestChunk != nil {
+				fileViews[i].currChunk = closestChunk
+			}
+		}
+		fileViews[i].SetDisplayText()
+	}
+}
+
+// SetDisplayText updates the display for the fileView based on the currChunk.
+// The pane is filled with as many surrounding lines as fit in its visible
+// height, split above and below the current line by contextAbovePercent.
+// An extra page of lines in each direction is loaded for scrollback.
+// The current line is highlighted and the pane is scrolled so that it sits
+// at the configured position.
 func (fv *fileView) SetDisplayText() {
-	currStr := "[\"curr\"]" + string(fv.currChunk.FileChunkBytes) + "[\"\"]"
-	nextChunk := fv.currChunk.GetNextFileChunk()
-	prevChunk := fv.currChunk.GetPrevFileChunk()
+	_, _, width, height := fv.GetInnerRect()
+	if height < 1 {
+		height = defaultPaneHeight
+	}
+	fv.lastWidth, fv.lastHeight = width, height
+
+	rowsAbove := (height - 1) * contextAbovePercent / 100
+	rowsBelow := height - 1 - rowsAbove
+
+	// Walk backward until we have filled the rows above plus the scrollback
+	var prevChunks []*filechunk.FileChunk
+	var visibleRowsAbove int
+	var loadedRows int
+	for prev := fv.currChunk.GetPrevFileChunk(); prev != nil; prev = prev.GetPrevFileChunk() {
+		if loadedRows >= rowsAbove+scrollbackPages*height {
+			break
+		}
+		rows := rowsForLine(prev.FileChunkBytes, width)
+		if loadedRows+rows <= rowsAbove {
+			visibleRowsAbove += rows
+		}
+		loadedRows += rows
+		prevChunks = append(prevChunks, prev)
+	}
 
-	var prevStr string
-	var nextStr string
+	// Walk forward until we have filled the rows below plus the scrollback
+	var nextChunks []*filechunk.FileChunk
+	loadedRows = 0
+	for next := fv.currChunk.GetNextFileChunk(); next != nil; next = next.GetNextFileChunk() {
+		if loadedRows >= rowsBelow+scrollbackPages*height {
+			break
+		}
+		loadedRows += rowsForLine(next.FileChunkBytes, width)
+		nextChunks = append(nextChunks, next)
+	}
 
-	if nextChunk != nil {
-		nextStr = string(nextChunk.FileChunkBytes)
+	fv.displayChunks = fv.displayChunks[:0]
+	for i := len(prevChunks) - 1; i >= 0; i-- {
+		fv.displayChunks = append(fv.displayChunks, prevChunks[i])
 	}
+	fv.displayChunks = append(fv.displayChunks, fv.currChunk)
+	fv.displayChunks = append(fv.displayChunks, nextChunks...)
 
-	if prevChunk != nil {
-		prevStr = string(prevChunk.FileChunkBytes)
+	// Every line is its own region, named by its index in displayChunks,
+	// so that clicking on a line tells us which chunk was selected.
+	var text strings.Builder
+	var rowsBeforeCurr int
+	for i, chunk := range fv.displayChunks {
+		if i < len(prevChunks) {
+			rowsBeforeCurr += rowsForLine(chunk.FileChunkBytes, width)
+		}
+		text.WriteString("[\"" + strconv.Itoa(i) + "\"]")
+		text.WriteString(renderLogLine(chunk.FileChunkBytes))
+		text.WriteString("[\"\"]")
 	}
 
-	fv.Highlight("curr")
-	fv.ScrollToHighlight()
-	fv.SetText(prevStr + currStr + nextStr)
+	fv.SetText(text.String())
+	fv.Highlight(strconv.Itoa(len(prevChunks)))
+	fv.ScrollTo(rowsBeforeCurr-visibleRowsAbove, 0)
+}
+
+// Draw refreshes the display text whenever the pane has been resized
+// so that the context always fills the visible height, and then draws
+// the underlying TextView.
+func (fv *fileView) Draw(screen tcell.Screen) {
+	_, _, width, height := fv.GetInnerRect()
+	if fv.currChunk != nil && (width != fv.lastWidth || height != fv.lastHeight) {
+		fv.SetDisplayText()
+	}
+	fv.SetTitle(fv.statusTitle())
+	fv.TextView.Draw(screen)
 }
 
 // LoadInputHandler sets the key commands for the file view.
@@ -179,6 +440,62 @@ func (fv *fileView) LoadInputHandler() {
 			}
 		}
 	})
+
+	// Clicking on a line highlights its region, which selects that line
+	// and syncs all the other files to its time.
+	fv.SetHighlightedFunc(func(added, removed, remaining []string) {
+		if len(added) == 0 {
+			return
+		}
+		displayIndex, err := strconv.Atoi(added[0])
+		if err != nil || displayIndex < 0 || displayIndex >= len(fv.displayChunks) {
+			return
+		}
+		selectedChunk := fv.displayChunks[displayIndex]
+		if selectedChunk != fv.currChunk {
+			SyncAllToFileChunk(fv.allFileViews, fv.index, selectedChunk)
+		}
+	})
+}
+
+// selectAdjacentLine moves the cursor line in this file to the next or
+// previous line and syncs all the other files to its time.
+func (fv *fileView) selectAdjacentLine(forward bool) {
+	var selectedChunk *filechunk.FileChunk
+	if forward {
+		selectedChunk = fv.currChunk.GetNextFileChunk()
+	} else {
+		selectedChunk = fv.currChunk.GetPrevFileChunk()
+	}
+	if selectedChunk != nil {
+		SyncAllToFileChunk(fv.allFileViews, fv.index, selectedChunk)
+	}
+}
+
+// MouseHandler lets the TextView handle the mouse, but when a click gives
+// the TextView the focus, the file view gets it instead, so that the keys
+// that act on the focused file view still find it after a click.
+func (fv *fileView) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	handler := fv.TextView.MouseHandler()
+	return func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		return handler(action, event, func(p tview.Primitive) {
+			if p == fv.TextView {
+				p = fv
+			}
+			setFocus(p)
+		})
+	}
+}
+
+// focusedFileView returns the index of the file view that has the focus,
+// or -1 if the focus is somewhere else
+func focusedFileView(fileViews []fileView, focus tview.Primitive) int {
+	for i := range fileViews {
+		if focus == &fileViews[i] || focus == fileViews[i].TextView {
+			return i
+		}
+	}
+	return -1
 }
 
 // newFileView creates a new FileView for the given file and and logFilename
@@ -186,6 +503,11 @@ func (fv *fileView) LoadInputHandler() {
 func newFileView(file *os.File, logFilename string, index int) *fileView {
 	head, tail := filechunk.NewFileChunk(file)
 
+	fileInfo, err := file.Stat()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	dataStr := string(tail.FileChunkBytes)
 	textView := tview.NewTextView().
 		SetDynamicColors(true).
@@ -197,12 +519,14 @@ func newFileView(file *os.File, logFilename string, index int) *fileView {
 	textView.SetTitle(logFilename)
 
 	return &fileView{
-		TextView:  textView,
-		file:      file,
-		headChunk: head,
-		tailChunk: tail,
-		currChunk: head,
-		index:     index,
+		TextView:    textView,
+		file:        file,
+		headChunk:   head,
+		tailChunk:   tail,
+		currChunk:   head,
+		index:       index,
+		logFilename: logFilename,
+		fileSize:    fileInfo.Size(),
 	}
 }
 
@@ -210,20 +534,111 @@ func newFileView(file *os.File, logFilename string, index int) *fileView {
 // box at the bottom
 var currCommand string
 
+// runBatchSteps is how many steps run until takes between checking for Esc
+const runBatchSteps = 2000
+
+// countOf returns the number of things as text, like "1 step" or "5 steps"
+func countOf(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return strconv.Itoa(n) + " " + noun + "s"
+}
+
+// viewNames are the pages of the different ways of viewing the files,
+// in the order the next-view key action goes through them
+var viewNames = []string{"panes", "grid", "merged"}
+
 // RunLogSync is the main tview function that builds the UI
 // Right now, it consists of one text box for each file being viewed,
 // and they are stacked on top of each other.
 // Below that is an edit box for entering commands.
+// Keys are handled by the keymap chosen in the config, and pressing
+// '?' or F1 shows the keys of the active keymap. Esc in the command box
+// gives the focus back to the panes.
 //
 // Here are the valid commands:
 // "head" jumps all files to the beginning
 // "tail" jumps all files to the end
+// "+5s", "-200ms", "+1m" step all files forward or backward by that duration
+// "quantum 500ms" sets how far the '[' and ']' keys step in time
+// "/text" finds the next log line in any file that contains text
+// "view grid" shows the files side by side with rows aligned by time,
+// "view merged" shows the lines of all the files merged into one pane in time order,
+// and "view panes" goes back to the stacked file views
+// "layout rows", "layout columns" and "layout grid" arrange the file views
+// "zoom N" shows only file view N full screen, and "zoom" shows them all again
+// "hide N", "show N" and "show all" hide and show file views, which keep moving in sync while hidden
+// "weight N W" makes file view N take W times as much space as the others
+// "bucket 50ms" sets how much time each row of the grid view covers
+// "ansi strip" removes the ANSI colors of colorized log lines, and "ansi colors" shows them
+// "highlight PATTERN fg=COLOR" colors matching text in every pane on top of the
+// highlight rules from the config, and "unhighlight N" removes a rule
+// "context N" shows N percent of each pane's context above the current line
+// "back" and "forward" go through the positions from before each jump
+// "mark NAME" marks the position of every file, "jump NAME" goes back to it,
+// "marks" lists them and "measure A B" shows the time between two marks
+// "alias NAME COMMAND" makes NAME run COMMAND, and "unalias NAME" removes it
 // Any positive number jumps that many steps, where each step chooses the next
 // log line based on time stamp and advancing that file foward one.
 // Any negative number goes back that many steps.
 // Also it is possible to search based on a timestamp like
-// "2020-05-25|08:47:33.663" to jump to the closest log line for all the files
-func RunLogSync(args []string) {
+// "2020-05-25|08:47:33.663" to jump to the closest log line for all the files.
+// See ParseTimeInput for all the ways a time can be written.
+// The commands are kept in a commandRegistry, and "help" lists them all.
+// "annotate TEXT" attaches a note to a log line, "annotations" lists them
+// and "export report.md" writes a report of them with context from every file
+// "save FILE" saves the session, which can be opened again with logsync --session FILE
+// "note TEXT" adds a note to the session and "notes" lists them
+// "run until CONDITION" steps until a condition over the watched values of the
+// files or the latest line is true, and "run back until CONDITION" steps back
+// "watch height" shows the latest height of every file in the watch panel,
+// with the values that differ between the files highlighted
+// "follow ID" steps only through the lines with ID, which is highlighted
+// everywhere, and lists them, "hits N" jumps to one of them, and "unfollow"
+// steps through all lines again
+// "diverge height appHash" finds the first height where the files have
+// different app hashes and jumps every file to its line for that height
+// "gaps 5s" lists the times a file logs nothing for more than 5 seconds,
+// longest first, and "gap N" jumps all the files to the start of one
+// "templates" lists the message templates of the lines with how often each
+// file has them, rarest first, and "template N" jumps to the lines of one
+// "latency height=(\d+)" measures how long after the first file every file
+// logs each event, and "clock node2 -150ms" sets a file's clock offset
+// "restarts" lists where the files restart, and "restart" and "restart back"
+// jump to the next and previous restart of any file
+// If a command or time cannot be understood, the error is shown above
+// the command box.
+func RunLogSync(args []string, config *Config, session *Session) {
+	if session == nil {
+		session = &Session{}
+	}
+
+	// The theme has to be applied before any of the views are created
+	var configErrors []error
+	if t, err := newTheme(config.Theme, config.Colors); t != nil {
+		currentTheme = t
+		if err != nil {
+			configErrors = append(configErrors, err)
+		}
+	} else {
+		configErrors = append(configErrors, err)
+	}
+	currentTheme.apply()
+	for _, rule := range config.Highlights {
+		if err := rule.compile(); err != nil {
+			configErrors = append(configErrors, err)
+		} else {
+			highlightRules = append(highlightRules, rule)
+		}
+	}
+
+	restartPattern, err := compileRestartPattern(config.RestartPattern)
+	if err != nil {
+		configErrors = append(configErrors, err)
+		restartPattern, _ = compileRestartPattern("")
+	}
+
 	app := tview.NewApplication()
 	mainFlex := tview.NewFlex().SetDirection(tview.FlexRow)
 	flexRows := tview.NewFlex().SetDirection(tview.FlexRow)
@@ -239,61 +654,1500 @@ func RunLogSync(args []string) {
 
 	for i := range fileViews {
 		fileViews[i].allFileViews = fileViews
+		fileViews[i].queueUpdateDraw = func(f func()) { app.QueueUpdateDraw(f) }
 		fileViews[i].LoadInputHandler()
-		flexRows = flexRows.AddItem(fileViews[i], 0, 1, false)
+	}
+
+	// messageView is the line above the command box where errors are shown
+	messageView := tview.NewTextView().SetDynamicColors(true)
+	showError := func(err error) {
+		messageView.SetText("[" + currentTheme.Error + "]" + tview.Escape(err.Error()))
+	}
+	for _, err := range configErrors {
+		showError(err)
+	}
+
+	layout := newPaneLayout(len(fileViews))
+	if config.Layout != "" {
+		if err := layout.applyLayoutCommand("layout", []string{config.Layout}); err != nil {
+			showError(err)
+		}
+	}
+	layout.arrange(flexRows, fileViews)
+
+	km, err := newKeymap(config.Keymap, config.Keys)
+	if err != nil {
+		showError(err)
+	}
+	if km == nil {
+		km, _ = newKeymap("", nil)
+	}
+
+	// viewPages holds the different ways of viewing the files, of which
+	// one is shown at a time
+	gridView := newGridView(fileViews)
+	mergedView := newMergedView(fileViews)
+	viewPages := tview.NewPages().
+		AddPage("panes", flexRows, true, true).
+		AddPage("grid", gridView, true, false).
+		AddPage("merged", mergedView, true, false)
+
+	refreshAll := func() {
+		for i := range fileViews {
+			fileViews[i].SetDisplayText()
+		}
+		mergedView.lastChunks = nil
+	}
+
+	rearrange := func() {
+		layout.arrange(flexRows, fileViews)
+		refreshAll()
+	}
+
+	switchView := func(viewName string) error {
+		if !viewPages.HasPage(viewName) {
+			return fmt.Errorf("unknown view %q, expected panes, grid or merged", viewName)
+		}
+		refreshAll()
+		viewPages.SwitchToPage(viewName)
+		return nil
+	}
+
+	search := func(text string, forward bool) error {
+		if text == "" {
+			return fmt.Errorf("no search yet, type /text in the command box")
+		} else if !SearchAll(fileViews, text, forward) {
+			return fmt.Errorf("%q not found", text)
+		}
+		return nil
 	}
 
 	inputField := tview.NewInputField().
 		SetLabel("> ").
-		SetFieldBackgroundColor(tcell.ColorBlack).
+		SetFieldBackgroundColor(themeColor(currentTheme.Input)).
 		SetFieldWidth(80).
 		SetChangedFunc(func(text string) {
 			currCommand = text
-		}).
-		SetDoneFunc(func(key tcell.Key) {
-			if key == tcell.KeyEnter {
-				numSteps, err := strconv.Atoi(currCommand)
-				if err == nil {
-					if numSteps > 0 {
-						for i := 0; i < numSteps; i++ {
-							next := AdvanceNextFileViewForward(fileViews)
-							if next < 0 {
-								break
-							}
-						}
-					} else if numSteps < 0 {
-						numSteps *= -1
-						for i := 0; i < numSteps; i++ {
-							prev := AdvancePrevFileViewBackward(fileViews)
-							if prev < 0 {
-								break
-							}
-						}
-					}
+		})
 
-					for i := range fileViews {
-						fileViews[i].SetDisplayText()
-					}
+	// focusables returns the views in the current page that can have the focus
+	focusables := func() []tview.Primitive {
+		switch viewName, _ := viewPages.GetFrontPage(); viewName {
+		case "grid":
+			return []tview.Primitive{gridView}
+		case "merged":
+			return []tview.Primitive{mergedView}
+		}
+		var panes []tview.Primitive
+		for _, i := range layout.visibleIndices() {
+			panes = append(panes, &fileViews[i])
+		}
+		return panes
+	}
+
+	// focusPane moves the focus by delta through the focusable views
+	focusPane := func(delta int) {
+		panes := focusables()
+		if len(panes) == 0 {
+			return
+		}
+		next := 0
+		if delta < 0 {
+			next = len(panes) - 1
+		}
+		for i, pane := range panes {
+			if pane == app.GetFocus() {
+				next = (i + delta + len(panes)) % len(panes)
+			}
+		}
+		app.SetFocus(panes[next])
+	}
+
+	// focusedIndex returns the index of the file view with the focus, or -1
+	focusedIndex := func() int {
+		return focusedFileView(fileViews, app.GetFocus())
+	}
+
+	// hits are the lines with the followed ID, once the scan for the ID
+	// in hitsID is done
+	var hits []*followHit
+	var hitsID string
+
+	// stepHit moves all the files to the next or previous line with the
+	// followed ID. It steps through the hits once they have been found,
+	// and searches the files for the ID while they are still being found.
+	stepHit := func(forward bool) bool {
+		if hitsID != followID {
+			return SearchAll(fileViews, followID, forward)
+		}
+		i := adjacentHit(fileViews, hits, forward)
+		if i < 0 {
+			return false
+		}
+		hit := hits[i]
+		SyncAllToFileChunk(fileViews, hit.file, fileViews[hit.file].currChunk.GetFileChunkAtOffset(hit.offset))
+		return true
+	}
+
+	step := func(forward bool) {
+		if followID != "" {
+			if !stepHit(forward) {
+				showError(fmt.Errorf("no more lines with %s", followID))
+			}
+			return
+		}
+		var index int
+		if forward {
+			index = AdvanceNextFileViewForward(fileViews)
+		} else {
+			index = AdvancePrevFileViewBackward(fileViews)
+		}
+		if index > -1 {
+			fileViews[index].SetDisplayText()
+		}
+	}
+
+	// selectLine moves the cursor line of the focused file view, or steps
+	// in time order when no file view has the focus
+	selectLine := func(forward bool) {
+		if i := focusedIndex(); i > -1 {
+			fileViews[i].selectAdjacentLine(forward)
+		} else {
+			step(forward)
+		}
+	}
+
+	commands := newCommandRegistry()
+	marks := newMarkList()
+
+	// runUntil steps until the condition is true, in batches so that Esc can
+	// stop it in between. The views are only updated where it stops.
+	var running, cancelRun bool
+	runUntil := func(cond condition, conditionText string, forward bool) {
+		running, cancelRun = true, false
+		var steps int
+		var runBatch func()
+		stop := func(message string) {
+			running = false
+			refreshAll()
+			messageView.SetText(tview.Escape(message))
+			go app.QueueUpdateDraw(func() {})
+		}
+		runBatch = func() {
+			for i := 0; i < runBatchSteps; i++ {
+				if cancelRun {
+					stop("stopped after " + countOf(steps, "step"))
+					return
+				}
+				var index int
+				if forward {
+					index = AdvanceNextFileViewForward(fileViews)
 				} else {
-					if currCommand == "tail" {
-						MoveAllToEnd(fileViews)
-					} else if currCommand == "head" {
-						MoveAllToBeginning(fileViews)
-					} else {
-						timeStamp := filechunk.GetTimeStampFromLine(currCommand)
-						if timeStamp > 1 {
-							MoveAllToTime(fileViews, timeStamp)
-						}
+					index = AdvancePrevFileViewBackward(fileViews)
+				}
+				if index < 0 {
+					stop(conditionText + " never became true in " + countOf(steps, "step"))
+					return
+				}
+				steps++
+				if cond.test(&condContext{fileViews: fileViews, lineIndex: index, line: fileViews[index].currChunk}) {
+					stop(conditionText + " after " + countOf(steps, "step"))
+					return
+				}
+			}
+			go app.QueueUpdate(runBatch)
+		}
+		go app.QueueUpdate(runBatch)
+	}
+
+	// The watch panel above the timeline is only as tall as the watches need
+	watches := &watchList{}
+	watchPanel := newWatchPanel(fileViews, watches)
+	resizeWatchPanel := func() {
+		mainFlex.ResizeItem(watchPanel, watchPanel.height(), 0)
+	}
+	nav := &navHistory{}
+
+	// The files are scanned for restarts in the background, and
+	// restartScansLeft is how many of the scans are not done yet
+	restartScansLeft := len(fileViews)
+	for i := range fileViews {
+		go func(i int) {
+			restarts, err := scanRestarts(fileViews[i].file, i, restartPattern)
+			app.QueueUpdateDraw(func() {
+				restartScansLeft--
+				if err != nil {
+					showError(err)
+					return
+				}
+				fileViews[i].restarts = restarts
+			})
+		}(i)
+	}
+
+	// jumpToRestart moves every file to the start of the run, and returns
+	// the message that says where it is
+	jumpToRestart := func(r *restart) string {
+		nav.record(fileViews)
+		SyncAllToFileChunk(fileViews, r.file, fileViews[r.file].currChunk.GetFileChunkAtOffset(r.offset))
+		return fmt.Sprintf("%s restarts, run %d: %s", uniqueNames(logFilenames(fileViews))[r.file], runNumber(fileViews[r.file].restarts, r.offset), r.reason)
+	}
+
+	// jumpToAdjacentRestart jumps to the closest restart of any file in
+	// time in the direction
+	jumpToAdjacentRestart := func(forward bool) (string, error) {
+		r := adjacentRestart(fileViews, allRestarts(fileViews), forward)
+		if r == nil && restartScansLeft > 0 {
+			return "", fmt.Errorf("still looking for restarts")
+		}
+		if r == nil {
+			return "", fmt.Errorf("no more restarts")
+		}
+		return jumpToRestart(r), nil
+	}
+	showRestart := func(message string, err error) {
+		if err != nil {
+			showError(err)
+		} else {
+			messageView.SetText(tview.Escape(message))
+		}
+	}
+
+	// jumpToAdjacentMark jumps to the closest mark in time in the direction
+	jumpToAdjacentMark := func(forward bool) {
+		m := marks.adjacent(CurrentClusterTime(fileViews), forward)
+		if m == nil {
+			showError(fmt.Errorf("no more marks"))
+			return
+		}
+		nav.record(fileViews)
+		marks.jumpTo(m, fileViews)
+		messageView.SetText(tview.Escape("mark " + m.Name))
+	}
+
+	helpView := tview.NewTextView()
+	helpView.SetBorder(true).SetTitle("help | Esc or q to close")
+	helpFlex := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(helpView, 0, 8, true).
+			AddItem(nil, 0, 1, false), 100, 0, true).
+		AddItem(nil, 0, 1, false)
+
+	// rootPages shows the help and other long text on top of everything
+	// else, and focusBeforeHelp is where the focus goes back to when it
+	// is closed
+	rootPages := tview.NewPages()
+	var focusBeforeHelp tview.Primitive
+	showOverlay := func(title string, text string) {
+		focusBeforeHelp = app.GetFocus()
+		helpView.SetTitle(title + " | Esc or q to close")
+		helpView.SetText(text).ScrollToBeginning()
+		rootPages.ShowPage("help")
+	}
+	showHelp := func() {
+		showOverlay("help", km.helpText()+"\n"+commands.helpText())
+	}
+
+	// follow starts following the ID, or the ID in the current line if it
+	// is empty, and scans the files for its hits in the background to show
+	// the summary of them. It returns the message that says what it is doing.
+	follow := func(id string) (string, error) {
+		if id == "" {
+			index := focusedIndex()
+			if index < 0 {
+				index = activeFileIndex(fileViews)
+			}
+			if id = findID(fileViews[index].currChunk.FileChunkBytes); id == "" {
+				return "", fmt.Errorf("no ID in the current line of %s, type follow ID", fileViews[index].logFilename)
+			}
+		}
+		setFollowID(id)
+		refreshAll()
+		hits, hitsID = nil, ""
+		files := make([]io.ReaderAt, len(fileViews))
+		for i := range fileViews {
+			files[i] = fileViews[i].file
+		}
+		fileNames := logFilenames(fileViews)
+		go func() {
+			found, err := findFollowHits(files, id)
+			app.QueueUpdateDraw(func() {
+				if followID != id {
+					return
+				}
+				if err != nil {
+					showError(err)
+					return
+				}
+				hits, hitsID = found, id
+				messageView.SetText(tview.Escape(fmt.Sprintf("following %s, %d lines, type hits N to jump to one", id, len(hits))))
+				showOverlay("follow "+id, describeFollowHits(fileNames, id, hits))
+			})
+		}()
+		return "looking for the lines with " + id, nil
+	}
+
+	// jumpToDivergence moves every file to the line of its value for the
+	// key that the files disagree on, and the files without a value to the
+	// time of the earliest of those lines
+	jumpToDivergence := func(d *divergence) {
+		nav.record(fileViews)
+		var earliest int64
+		for _, v := range d.values {
+			if v != nil && (earliest == 0 || v.time < earliest) {
+				earliest = v.time
+			}
+		}
+		chunks := make([]*filechunk.FileChunk, len(fileViews))
+		for i, v := range d.values {
+			if v != nil {
+				chunks[i] = fileViews[i].currChunk.GetFileChunkAtOffset(v.offset)
+			} else {
+				chunks[i] = fileViews[i].chunkClosestToTime(earliest)
+			}
+		}
+		restorePosition(fileViews, chunks)
+	}
+
+	keyActionFuncs := map[string]func(){
+		"step-forward":     func() { step(true) },
+		"step-backward":    func() { step(false) },
+		"select-next-line": func() { selectLine(true) },
+		"select-prev-line": func() { selectLine(false) },
+		"time-forward":     func() { StepAllByDuration(fileViews, timeQuantum) },
+		"time-backward":    func() { StepAllByDuration(fileViews, -timeQuantum) },
+		"head": func() {
+			nav.record(fileViews)
+			MoveAllToBeginning(fileViews)
+		},
+		"tail": func() {
+			nav.record(fileViews)
+			MoveAllToEnd(fileViews)
+		},
+		"nav-back": func() {
+			if !nav.goBack(fileViews) {
+				showError(fmt.Errorf("nothing to go back to"))
+			}
+		},
+		"nav-forward": func() {
+			if !nav.goForward(fileViews) {
+				showError(fmt.Errorf("nothing to go forward to"))
+			}
+		},
+		"add-mark": func() {
+			m := marks.set("", fileViews)
+			messageView.SetText(tview.Escape("set mark " + m.Name))
+		},
+		"next-mark":    func() { jumpToAdjacentMark(true) },
+		"prev-mark":    func() { jumpToAdjacentMark(false) },
+		"next-restart": func() { showRestart(jumpToAdjacentRestart(true)) },
+		"prev-restart": func() { showRestart(jumpToAdjacentRestart(false)) },
+		"annotate": func() {
+			if i := focusedIndex(); i > -1 {
+				inputField.SetText(fmt.Sprintf("annotate #%d ", i+1))
+			} else {
+				inputField.SetText("annotate ")
+			}
+			app.SetFocus(inputField)
+		},
+		"search": func() {
+			inputField.SetText("/")
+			app.SetFocus(inputField)
+		},
+		"search-next": func() {
+			nav.record(fileViews)
+			if err := search(lastSearch, true); err != nil {
+				showError(err)
+			}
+		},
+		"search-prev": func() {
+			nav.record(fileViews)
+			if err := search(lastSearch, false); err != nil {
+				showError(err)
+			}
+		},
+		"follow": func() {
+			if followID != "" {
+				setFollowID("")
+				refreshAll()
+				messageView.SetText("stopped following")
+			} else if message, err := follow(""); err != nil {
+				showError(err)
+			} else {
+				messageView.SetText(tview.Escape(message))
+			}
+		},
+		"focus-next-pane": func() { focusPane(1) },
+		"focus-prev-pane": func() { focusPane(-1) },
+		"focus-command":   func() { app.SetFocus(inputField) },
+		"zoom-pane": func() {
+			if layout.Zoomed >= 0 {
+				layout.Zoomed = -1
+			} else if i := focusedIndex(); i > -1 {
+				layout.Zoomed = i
+			} else {
+				showError(fmt.Errorf("focus a pane to zoom it"))
+				return
+			}
+			rearrange()
+		},
+		"next-layout": func() {
+			arrangements := []string{arrangeRows, arrangeColumns, arrangeGrid}
+			for i, arrangement := range arrangements {
+				if arrangement == layout.Arrangement {
+					layout.Arrangement = arrangements[(i+1)%len(arrangements)]
+					break
+				}
+			}
+			rearrange()
+		},
+		"next-view": func() {
+			currView, _ := viewPages.GetFrontPage()
+			for i, viewName := range viewNames {
+				if viewName == currView {
+					switchView(viewNames[(i+1)%len(viewNames)])
+					break
+				}
+			}
+			if panes := focusables(); len(panes) > 0 {
+				app.SetFocus(panes[0])
+			}
+		},
+		"help": showHelp,
+		"quit": func() { app.Stop() },
+	}
+
+	// The fallback handles the lines that do not start with a command:
+	// step counts, durations to step by, searches and times to jump to
+	commands.fallback = func(line string) (string, error) {
+		if numSteps, err := strconv.Atoi(line); err == nil && !isEpochInput(line) {
+			if numSteps >= largeStepLines || numSteps <= -largeStepLines {
+				nav.record(fileViews)
+			}
+			if followID != "" {
+				for i := 0; i < numSteps; i++ {
+					if !stepHit(true) {
+						break
 					}
 				}
+				for i := 0; i > numSteps; i-- {
+					if !stepHit(false) {
+						break
+					}
+				}
+				return "", nil
 			}
+			for i := 0; i < numSteps; i++ {
+				if AdvanceNextFileViewForward(fileViews) < 0 {
+					break
+				}
+			}
+			for i := 0; i > numSteps; i-- {
+				if AdvancePrevFileViewBackward(fileViews) < 0 {
+					break
+				}
+			}
+			refreshAll()
+			return "", nil
+		}
+		if strings.HasPrefix(line, "/") {
+			lastSearch = strings.TrimPrefix(line, "/")
+			nav.record(fileViews)
+			return "", search(lastSearch, true)
+		}
+		if duration, err := time.ParseDuration(line); err == nil && (line[0] == '+' || line[0] == '-') {
+			nav.record(fileViews)
+			StepAllByDuration(fileViews, duration)
+			return "", nil
+		}
+		timeStamp, err := ParseTimeInput(line, GetTimeReference(fileViews))
+		if err != nil {
+			return "", fmt.Errorf("%q is not a command or a time (type help to see the commands): %v", line, err)
+		}
+		nav.record(fileViews)
+		MoveAllToTime(fileViews, timeStamp)
+		return "", nil
+	}
+	commands.words = func() []string {
+		return fieldNames(fileViews)
+	}
+
+	completeWords := func(words ...string) func([]string) []string {
+		return func(args []string) []string {
+			if len(args) > 0 {
+				return nil
+			}
+			return words
+		}
+	}
+	completePanes := func(args []string) []string {
+		if len(args) > 0 {
+			return nil
+		}
+		return uniqueNames(logFilenames(fileViews))
+	}
+
+	commands.register(&command{
+		name: "head",
+		help: "jump all files to the beginning",
+		run: func(args []string) (string, error) {
+			nav.record(fileViews)
+			MoveAllToBeginning(fileViews)
+			return "", nil
+		},
+	})
+	commands.register(&command{
+		name: "tail",
+		help: "jump all files to the end",
+		run: func(args []string) (string, error) {
+			nav.record(fileViews)
+			MoveAllToEnd(fileViews)
+			return "", nil
+		},
+	})
+	commands.register(&command{
+		name:    "quantum",
+		usage:   "[DURATION]",
+		help:    "set how far the time keys step, like quantum 500ms",
+		maxArgs: 1,
+		run: func(args []string) (string, error) {
+			if len(args) == 1 {
+				quantum, err := time.ParseDuration(args[0])
+				if err != nil || quantum <= 0 {
+					return "", fmt.Errorf("bad quantum %q, expected a duration like 500ms", args[0])
+				}
+				timeQuantum = quantum
+			}
+			return "quantum is " + timeQuantum.String(), nil
+		},
+	})
+	commands.register(&command{
+		name:     "view",
+		usage:    "panes|grid|merged",
+		help:     "switch between the stacked panes, the time grid and the merged view",
+		minArgs:  1,
+		maxArgs:  1,
+		complete: completeWords(viewNames...),
+		run: func(args []string) (string, error) {
+			return "", switchView(args[0])
+		},
+	})
+
+	layoutCommands := []struct {
+		name, usage, help string
+		minArgs, maxArgs  int
+		complete          func([]string) []string
+	}{
+		{"layout", "rows|columns|grid", "arrange the panes in rows, columns or a grid", 1, 1, completeWords(arrangeRows, arrangeColumns, arrangeGrid)},
+		{"zoom", "[N]", "show only pane N full screen, or all the panes again", 0, 1, completePanes},
+		{"hide", "N", "hide pane N, which keeps moving in sync while hidden", 1, 1, completePanes},
+		{"show", "N|all", "show pane N again, or all the panes", 1, 1, func(args []string) []string {
+			if len(args) > 0 {
+				return nil
+			}
+			return append([]string{"all"}, uniqueNames(logFilenames(fileViews))...)
+		}},
+		{"weight", "N W", "make pane N take W times as much space as the others", 2, 2, completePanes},
+	}
+	for _, layoutCommand := range layoutCommands {
+		name := layoutCommand.name
+		commands.register(&command{
+			name:     name,
+			usage:    layoutCommand.usage,
+			help:     layoutCommand.help,
+			minArgs:  layoutCommand.minArgs,
+			maxArgs:  layoutCommand.maxArgs,
+			complete: layoutCommand.complete,
+			run: func(args []string) (string, error) {
+				if name != "layout" && len(args) > 0 {
+					args[0] = resolvePaneName(fileViews, args[0])
+				}
+				if err := layout.applyLayoutCommand(name, args); err != nil {
+					return "", err
+				}
+				rearrange()
+				return "", nil
+			},
 		})
+	}
+
+	commands.register(&command{
+		name:     "ansi",
+		usage:    "[colors|strip]",
+		help:     "show the ANSI colors of colorized log lines, or strip them",
+		maxArgs:  1,
+		complete: completeWords(ansiColors, ansiStrip),
+		run: func(args []string) (string, error) {
+			if len(args) == 1 {
+				if args[0] != ansiColors && args[0] != ansiStrip {
+					return "", fmt.Errorf("expected ansi colors or strip")
+				}
+				ansiMode = args[0]
+				refreshAll()
+			}
+			return "ansi " + ansiMode, nil
+		},
+	})
+	commands.register(&command{
+		name:    "highlight",
+		usage:   "[PATTERN|FIELD=VALUE [fg=COLOR] [bg=COLOR] [bold] [line] [gutter=CHAR]]",
+		help:    "color the text matching PATTERN or the values of FIELD in every pane, or list the highlight rules",
+		maxArgs: -1,
+		run: func(args []string) (string, error) {
+			if len(args) > 0 {
+				rule, err := parseHighlightArgs(args)
+				if err != nil {
+					return "", err
+				}
+				highlightRules = append(highlightRules, rule)
+				refreshAll()
+			}
+			return describeHighlightRules(), nil
+		},
+	})
+	commands.register(&command{
+		name:    "unhighlight",
+		usage:   "N",
+		help:    "remove highlight rule N, as numbered by highlight",
+		minArgs: 1,
+		maxArgs: 1,
+		run: func(args []string) (string, error) {
+			number, err := strconv.Atoi(args[0])
+			if err != nil || number < 1 || number > len(highlightRules) {
+				return "", fmt.Errorf("bad highlight rule number %q, expected 1 to %d", args[0], len(highlightRules))
+			}
+			highlightRules = append(highlightRules[:number-1], highlightRules[number:]...)
+			refreshAll()
+			return describeHighlightRules(), nil
+		},
+	})
+	commands.register(&command{
+		name:    "bucket",
+		usage:   "[DURATION]",
+		help:    "set how much time each row of the grid view covers, like bucket 50ms",
+		maxArgs: 1,
+		run: func(args []string) (string, error) {
+			if len(args) == 1 {
+				size, err := time.ParseDuration(args[0])
+				if err != nil || size <= 0 {
+					return "", fmt.Errorf("bad bucket size %q, expected a duration like 50ms", args[0])
+				}
+				bucketSize = size
+			}
+			return "bucket is " + bucketSize.String(), nil
+		},
+	})
+	commands.register(&command{
+		name:    "context",
+		usage:   "[PERCENT]",
+		help:    "show PERCENT of each pane above the current line",
+		maxArgs: 1,
+		run: func(args []string) (string, error) {
+			if len(args) == 1 {
+				percent, err := strconv.Atoi(args[0])
+				if err != nil || percent < 0 || percent > 100 {
+					return "", fmt.Errorf("bad context %q, expected a percent from 0 to 100", args[0])
+				}
+				contextAbovePercent = percent
+				refreshAll()
+			}
+			return fmt.Sprintf("context is %d%%", contextAbovePercent), nil
+		},
+	})
+	commands.register(&command{
+		name: "back",
+		help: "go back to where all the files were before the last jump",
+		run: func(args []string) (string, error) {
+			if !nav.goBack(fileViews) {
+				return "", fmt.Errorf("nothing to go back to")
+			}
+			return "", nil
+		},
+	})
+	commands.register(&command{
+		name: "forward",
+		help: "go forward again to where back came from",
+		run: func(args []string) (string, error) {
+			if !nav.goForward(fileViews) {
+				return "", fmt.Errorf("nothing to go forward to")
+			}
+			return "", nil
+		},
+	})
+
+	commands.register(&command{
+		name:    "watch",
+		usage:   "[NAME [REGEX|FIELD=]]",
+		help:    "show the latest value of field NAME, or of the first group of REGEX, in every file as you step, or list the watches",
+		maxArgs: -1,
+		run: func(args []string) (string, error) {
+			if len(args) > 0 {
+				if err := watches.add(parseWatchArgs(args)); err != nil {
+					return "", err
+				}
+				resizeWatchPanel()
+			}
+			return watches.describe(), nil
+		},
+	})
+	commands.register(&command{
+		name:    "unwatch",
+		usage:   "NAME",
+		help:    "stop showing the watch NAME",
+		minArgs: 1,
+		maxArgs: 1,
+		complete: func(args []string) []string {
+			if len(args) > 0 {
+				return nil
+			}
+			var names []string
+			for _, w := range watches.watches {
+				names = append(names, w.Name)
+			}
+			return names
+		},
+		run: func(args []string) (string, error) {
+			if !watches.remove(args[0]) {
+				return "", fmt.Errorf("no watch %q", args[0])
+			}
+			resizeWatchPanel()
+			return watches.describe(), nil
+		},
+	})
+	commands.register(&command{
+		name:    "run",
+		usage:   "[back] until CONDITION",
+		help:    "step forward, or back, until CONDITION is true, like run until node0.height - node2.height > 2 or run until line ~ (timeout|panic)",
+		minArgs: 2,
+		maxArgs: -1,
+		run: func(args []string) (string, error) {
+			forward := true
+			if args[0] == "back" {
+				forward = false
+				args = args[1:]
+			}
+			if len(args) < 2 || args[0] != "until" {
+				return "", fmt.Errorf("expected run until CONDITION or run back until CONDITION")
+			}
+			conditionText := strings.Join(args[1:], " ")
+			cond, err := parseCondition(conditionText, fileViews, watches)
+			if err != nil {
+				return "", err
+			}
+			nav.record(fileViews)
+			runUntil(cond, conditionText, forward)
+			return "running until " + conditionText + " (Esc to stop)", nil
+		},
+	})
+	commands.register(&command{
+		name:    "follow",
+		usage:   "[ID]",
+		help:    "follow ID, or the ID in the current line, stepping only through the lines with it and listing them",
+		maxArgs: 1,
+		run: func(args []string) (string, error) {
+			id := ""
+			if len(args) == 1 {
+				id = args[0]
+			}
+			return follow(id)
+		},
+	})
+	commands.register(&command{
+		name:    "hits",
+		usage:   "[N]",
+		help:    "list the lines with the followed ID again, or jump all the files to line N of the list",
+		maxArgs: 1,
+		run: func(args []string) (string, error) {
+			if followID == "" {
+				return "", fmt.Errorf("not following an ID, type follow ID")
+			}
+			if len(args) == 0 {
+				return follow(followID)
+			}
+			i, err := parseHitNumber(args[0], hits)
+			if err != nil {
+				return "", err
+			}
+			hit := hits[i]
+			nav.record(fileViews)
+			SyncAllToFileChunk(fileViews, hit.file, fileViews[hit.file].currChunk.GetFileChunkAtOffset(hit.offset))
+			return fmt.Sprintf("hit %d of %d: %s at %s", i+1, len(hits), uniqueNames(logFilenames(fileViews))[hit.file], time.Unix(0, hit.time).UTC().Format(markTimeFormat)), nil
+		},
+	})
+	commands.register(&command{
+		name: "unfollow",
+		help: "stop following the ID and step through all the lines again",
+		run: func(args []string) (string, error) {
+			if followID == "" {
+				return "", fmt.Errorf("not following an ID")
+			}
+			setFollowID("")
+			refreshAll()
+			return "stopped following", nil
+		},
+	})
+	commands.register(&command{
+		name:    "diverge",
+		usage:   "KEY VALUE",
+		help:    "find the first KEY where the files have different VALUEs, like diverge height appHash, and jump to its lines",
+		minArgs: 2,
+		maxArgs: -1,
+		run: func(args []string) (string, error) {
+			key, err := newExtractor(args[0], "key", watches)
+			if err != nil {
+				return "", err
+			}
+			value, err := newExtractor(strings.Join(args[1:], " "), "value", watches)
+			if err != nil {
+				return "", err
+			}
+			files := make([]io.ReaderAt, len(fileViews))
+			for i := range fileViews {
+				files[i] = fileViews[i].file
+			}
+			fileNames := logFilenames(fileViews)
+			go func() {
+				d, err := findDivergence(files, key, value)
+				app.QueueUpdateDraw(func() {
+					switch {
+					case err != nil:
+						showError(err)
+					case d == nil:
+						messageView.SetText(tview.Escape(fmt.Sprintf("the files agree on %s at every %s", value.Name, key.Name)))
+					default:
+						jumpToDivergence(d)
+						showOverlay("diverge "+key.Name+" "+value.Name, describeDivergence(fileNames, key, value, d))
+					}
+				})
+			}()
+			return fmt.Sprintf("looking for the first %s where %s differs", key.Name, value.Name), nil
+		},
+	})
+	// gaps are the gaps found by the last gaps command, longest first
+	var gaps []*gap
+	commands.register(&command{
+		name:    "gaps",
+		usage:   "[DURATION] [REGEX]",
+		help:    "list the times a file has no lines, or none matching REGEX, for more than DURATION (default 2s), longest first",
+		maxArgs: -1,
+		run: func(args []string) (string, error) {
+			minDuration, pattern, err := parseGapArgs(args)
+			if err != nil {
+				return "", err
+			}
+			files := make([]io.ReaderAt, len(fileViews))
+			for i := range fileViews {
+				files[i] = fileViews[i].file
+			}
+			fileNames := logFilenames(fileViews)
+			go func() {
+				found, total, err := findGaps(files, minDuration, pattern)
+				var report string
+				if err == nil {
+					report = describeGaps(fileNames, files, minDuration, pattern, found, total)
+				}
+				app.QueueUpdateDraw(func() {
+					if err != nil {
+						showError(err)
+						return
+					}
+					gaps = found
+					messageView.SetText(countOf(total, "gap") + ", type gap N to jump to one")
+					showOverlay("gaps", report)
+				})
+			}()
+			return fmt.Sprintf("looking for gaps longer than %s", minDuration), nil
+		},
+	})
+	commands.register(&command{
+		name:    "gap",
+		usage:   "N",
+		help:    "jump all the files to the start of gap N from the gaps list",
+		minArgs: 1,
+		maxArgs: 1,
+		run: func(args []string) (string, error) {
+			i, err := parseGapNumber(args[0], gaps)
+			if err != nil {
+				return "", err
+			}
+			g := gaps[i]
+			nav.record(fileViews)
+			if g.offset >= 0 && !g.startsFile {
+				SyncAllToFileChunk(fileViews, g.file, fileViews[g.file].currChunk.GetFileChunkAtOffset(g.offset))
+			} else {
+				MoveAllToTime(fileViews, g.start)
+			}
+			return fmt.Sprintf("gap %d: %s has nothing for %s", i+1, uniqueNames(logFilenames(fileViews))[g.file], g.duration()), nil
+		},
+	})
+	// templates are the message templates of all the files, found the
+	// first time they are listed, and lastTemplate and lastTemplateHit are
+	// the template and line that the template command last jumped to
+	var templates []*logTemplate
+	var templatesFull bool
+	lastTemplate, lastTemplateHit := -1, -1
+	templateFileNames := logFilenames(fileViews)
+	commands.register(&command{
+		name:    "templates",
+		usage:   "[partial]",
+		help:    "list the message templates of the lines, rarest first, with how many lines of each every file has, or only the ones some files do not have",
+		maxArgs: 1,
+		complete: func(args []string) []string {
+			return []string{"partial"}
+		},
+		run: func(args []string) (string, error) {
+			partial := len(args) == 1
+			if partial && args[0] != "partial" {
+				return "", fmt.Errorf("expected templates or templates partial")
+			}
+			if templates != nil {
+				showOverlay("templates", describeTemplates(templateFileNames, templates, templatesFull, partial))
+				return "type template N to jump to the lines of one", nil
+			}
+			files := make([]io.ReaderAt, len(fileViews))
+			for i := range fileViews {
+				files[i] = fileViews[i].file
+			}
+			go func() {
+				found, full, err := findTemplates(files)
+				app.QueueUpdateDraw(func() {
+					if err != nil {
+						showError(err)
+						return
+					}
+					templates, templatesFull = found, full
+					messageView.SetText(countOf(len(templates), "template") + ", type template N to jump to the lines of one")
+					showOverlay("templates", describeTemplates(templateFileNames, templates, templatesFull, partial))
+				})
+			}()
+			return "finding the message templates", nil
+		},
+	})
+	commands.register(&command{
+		name:    "template",
+		usage:   "N",
+		help:    "jump to the next line of template N from the templates list and sync all the files to it",
+		minArgs: 1,
+		maxArgs: 1,
+		run: func(args []string) (string, error) {
+			n, err := parseTemplateNumber(args[0], templates)
+			if err != nil {
+				return "", err
+			}
+			t := templates[n]
+			hit := nextTemplateHit(t, CurrentClusterTime(fileViews))
+			if n == lastTemplate {
+				hit = (lastTemplateHit + 1) % len(t.hits)
+			}
+			lastTemplate, lastTemplateHit = n, hit
+			nav.record(fileViews)
+			file := t.hits[hit].file
+			SyncAllToFileChunk(fileViews, file, fileViews[file].currChunk.GetFileChunkAtOffset(t.hits[hit].offset))
+			return describeTemplateHit(templateFileNames, n, t, hit), nil
+		},
+	})
+	// clockOffsets are added to the times of the files when latencies are
+	// measured, and latencyEvents are the events of the last measurement
+	clockOffsets, errs := parseClockOffsets(fileViews, config.ClockOffsets)
+	for _, err := range errs {
+		showError(err)
+	}
+	var latencyEvents []*latencyEvent
+	commands.register(&command{
+		name:    "clock",
+		usage:   "[FILE OFFSET]",
+		help:    "add OFFSET, like -150ms, to the times of FILE when measuring latencies, or list the clock offsets",
+		maxArgs: 2,
+		run: func(args []string) (string, error) {
+			if len(args) == 1 {
+				return "", fmt.Errorf("expected clock FILE OFFSET, like clock node2 -150ms")
+			}
+			if len(args) == 2 {
+				i, err := resolveFileRef(fileViews, args[0])
+				if err != nil {
+					return "", err
+				}
+				offset, err := time.ParseDuration(args[1])
+				if err != nil {
+					return "", fmt.Errorf("bad clock offset %q, expected a duration like -150ms", args[1])
+				}
+				clockOffsets[i] = offset
+			}
+			return describeClockOffsets(fileViews, clockOffsets), nil
+		},
+	})
+	commands.register(&command{
+		name:    "latency",
+		usage:   "REGEX | csv FILE",
+		help:    "measure how long after the first file every file logs each event matching REGEX, whose group captures the event ID, or write the last measurement as CSV",
+		minArgs: 1,
+		maxArgs: -1,
+		run: func(args []string) (string, error) {
+			fileNames := logFilenames(fileViews)
+			if args[0] == "csv" && len(args) == 2 {
+				if latencyEvents == nil {
+					return "", fmt.Errorf("no latencies to write, type latency REGEX first")
+				}
+				if err := exportLatencies(args[1], fileNames, latencyEvents); err != nil {
+					return "", err
+				}
+				return "wrote " + countOf(len(latencyEvents), "event") + " to " + args[1], nil
+			}
+			pattern, err := compileLatencyPattern(strings.Join(args, " "))
+			if err != nil {
+				return "", err
+			}
+			files := make([]io.ReaderAt, len(fileViews))
+			for i := range fileViews {
+				files[i] = fileViews[i].file
+			}
+			offsets := append([]time.Duration(nil), clockOffsets...)
+			go func() {
+				events, err := findLatencies(files, pattern, offsets)
+				app.QueueUpdateDraw(func() {
+					if err != nil {
+						showError(err)
+						return
+					}
+					latencyEvents = events
+					messageView.SetText(tview.Escape(countOf(len(events), "event") + ", type latency csv FILE to write them"))
+					showOverlay("latency", describeLatencies(fileNames, pattern, offsets, events))
+				})
+			}()
+			return "measuring the latency of " + pattern.String(), nil
+		},
+	})
+	commands.register(&command{
+		name: "restarts",
+		help: "list where the files restart, found by the restart pattern or the time going back",
+		run: func(args []string) (string, error) {
+			if restartScansLeft > 0 {
+				return "", fmt.Errorf("still looking for restarts")
+			}
+			showOverlay("restarts", describeRestarts(fileViews, restartPattern, allRestarts(fileViews)))
+			return "type restart N to jump to one", nil
+		},
+	})
+	commands.register(&command{
+		name:    "restart",
+		usage:   "[N|back]",
+		help:    "jump all the files to the next restart of any file, the previous one, or restart N from the restarts list",
+		maxArgs: 1,
+		complete: func(args []string) []string {
+			return []string{"back"}
+		},
+		run: func(args []string) (string, error) {
+			if len(args) == 0 || args[0] == "back" {
+				return jumpToAdjacentRestart(len(args) == 0)
+			}
+			restarts := allRestarts(fileViews)
+			i, err := parseRestartNumber(args[0], restarts)
+			if err != nil {
+				return "", err
+			}
+			return jumpToRestart(restarts[i]), nil
+		},
+	})
+	commands.register(&command{
+		name:    "save",
+		usage:   "[FILE]",
+		help:    "save the positions, marks, layout, notes, highlights, clock offsets and followed ID to a session file",
+		maxArgs: 1,
+		run: func(args []string) (string, error) {
+			filename := session.filename
+			if len(args) == 1 {
+				filename = args[0]
+			}
+			if filename == "" {
+				return "", fmt.Errorf("give a file to save the session to, like save incident.logsync")
+			}
+
+			session.Layout = layout
+			session.View, _ = viewPages.GetFrontPage()
+			session.Quantum = timeQuantum.String()
+			session.Bucket = bucketSize.String()
+			session.ContextPercent = contextAbovePercent
+			session.ANSI = ansiMode
+			session.LastSearch = lastSearch
+			session.Marks = marks.marks
+			session.Aliases = commands.aliases
+			session.Watches = watches.watches
+			session.Highlights = append([]*highlightRule{}, highlightRules...)
+			session.ClockOffsets = clockOffsetConfig(fileViews, clockOffsets)
+			session.FollowID = followID
+			if err := session.save(filename, fileViews); err != nil {
+				return "", err
+			}
+			return "saved session " + filename, nil
+		},
+	})
+	commands.register(&command{
+		name:    "note",
+		usage:   "TEXT",
+		help:    "add a note to the session",
+		minArgs: 1,
+		maxArgs: -1,
+		run: func(args []string) (string, error) {
+			session.Notes = append(session.Notes, strings.Join(args, " "))
+			return fmt.Sprintf("added note %d", len(session.Notes)), nil
+		},
+	})
+	commands.register(&command{
+		name: "notes",
+		help: "list the notes of the session",
+		run: func(args []string) (string, error) {
+			if len(session.Notes) == 0 {
+				return "no notes", nil
+			}
+			var notes []string
+			for i, note := range session.Notes {
+				notes = append(notes, fmt.Sprintf("%d. %s", i+1, note))
+			}
+			return strings.Join(notes, "  "), nil
+		},
+	})
+
+	commands.register(&command{
+		name:    "annotate",
+		usage:   "[#N] TEXT",
+		help:    "attach a note to the current line of pane N, or of the pane stepped last",
+		minArgs: 1,
+		maxArgs: -1,
+		run: func(args []string) (string, error) {
+			index, text, err := parseAnnotateArgs(fileViews, args)
+			if err != nil {
+				return "", err
+			}
+			session.Annotations = append(session.Annotations, newAnnotation(fileViews, index, text))
+			sortAnnotations(session.Annotations)
+			return fmt.Sprintf("annotated %s, %d annotations", uniqueNames(logFilenames(fileViews))[index], len(session.Annotations)), nil
+		},
+	})
+	commands.register(&command{
+		name: "annotations",
+		help: "list the annotations of all the files in time order",
+		run: func(args []string) (string, error) {
+			return describeAnnotations(fileViews, session.Annotations), nil
+		},
+	})
+	commands.register(&command{
+		name:    "annotation",
+		usage:   "N",
+		help:    "jump to the line of annotation N and sync all the files to it",
+		minArgs: 1,
+		maxArgs: 1,
+		run: func(args []string) (string, error) {
+			i, err := parseAnnotationNumber(args[0], session.Annotations)
+			if err != nil {
+				return "", err
+			}
+			a := session.Annotations[i]
+			chunk, err := annotationChunk(fileViews, a)
+			if err != nil {
+				return "", err
+			}
+			nav.record(fileViews)
+			SyncAllToFileChunk(fileViews, a.File, chunk)
+			return a.Text, nil
+		},
+	})
+	commands.register(&command{
+		name:    "unannotate",
+		usage:   "N",
+		help:    "remove annotation N",
+		minArgs: 1,
+		maxArgs: 1,
+		run: func(args []string) (string, error) {
+			i, err := parseAnnotationNumber(args[0], session.Annotations)
+			if err != nil {
+				return "", err
+			}
+			session.Annotations = append(session.Annotations[:i], session.Annotations[i+1:]...)
+			return fmt.Sprintf("removed annotation %s", args[0]), nil
+		},
+	})
+	commands.register(&command{
+		name:    "export",
+		usage:   "FILE",
+		help:    "write a report of the annotations with context from every file, as HTML for .html and Markdown otherwise",
+		minArgs: 1,
+		maxArgs: 1,
+		run: func(args []string) (string, error) {
+			if err := exportReport(args[0], fileViews, session.Annotations, session.Notes); err != nil {
+				return "", err
+			}
+			return "exported report " + args[0], nil
+		},
+	})
+
+	completeMarks := func(args []string) []string {
+		return marks.names()
+	}
+	commands.register(&command{
+		name:    "mark",
+		usage:   "[NAME]",
+		help:    "mark the current position of every file as NAME, or the next free name like m1",
+		maxArgs: 1,
+		run: func(args []string) (string, error) {
+			name := ""
+			if len(args) == 1 {
+				name = args[0]
+			}
+			m := marks.set(name, fileViews)
+			return "set mark " + m.Name, nil
+		},
+	})
+	commands.register(&command{
+		name:     "jump",
+		usage:    "NAME",
+		help:     "put every file back where it was when mark NAME was set",
+		minArgs:  1,
+		maxArgs:  1,
+		complete: completeMarks,
+		run: func(args []string) (string, error) {
+			m := marks.find(args[0])
+			if m == nil {
+				return "", fmt.Errorf("no mark %q", args[0])
+			}
+			nav.record(fileViews)
+			marks.jumpTo(m, fileViews)
+			return "", nil
+		},
+	})
+	commands.register(&command{
+		name: "marks",
+		help: "list the marks in time order with the time between them",
+		run: func(args []string) (string, error) {
+			return marks.describe(), nil
+		},
+	})
+	commands.register(&command{
+		name:     "unmark",
+		usage:    "NAME",
+		help:     "remove mark NAME",
+		minArgs:  1,
+		maxArgs:  1,
+		complete: completeMarks,
+		run: func(args []string) (string, error) {
+			if !marks.remove(args[0]) {
+				return "", fmt.Errorf("no mark %q", args[0])
+			}
+			return "removed mark " + args[0], nil
+		},
+	})
+	commands.register(&command{
+		name:     "measure",
+		usage:    "FROM [TO]",
+		help:     "show the time from mark FROM to mark TO, or to the current position",
+		minArgs:  1,
+		maxArgs:  2,
+		complete: completeMarks,
+		run: func(args []string) (string, error) {
+			to := ""
+			if len(args) == 2 {
+				to = args[1]
+			}
+			return marks.measure(args[0], to, fileViews)
+		},
+	})
+	commands.register(&command{
+		name:    "alias",
+		usage:   "[NAME [COMMAND...]]",
+		help:    "make NAME run COMMAND, show what NAME runs, or list the aliases",
+		maxArgs: -1,
+		run: func(args []string) (string, error) {
+			if len(args) == 0 {
+				if len(commands.aliases) == 0 {
+					return "no aliases", nil
+				}
+				var names []string
+				for name := range commands.aliases {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				return "aliases: " + strings.Join(names, ", "), nil
+			}
+			if len(args) == 1 {
+				expansion, ok := commands.aliases[args[0]]
+				if !ok {
+					return "", fmt.Errorf("no alias %q", args[0])
+				}
+				return args[0] + " = " + expansion, nil
+			}
+			if err := commands.setAlias(args[0], strings.Join(args[1:], " ")); err != nil {
+				return "", err
+			}
+			return args[0] + " = " + commands.aliases[args[0]], nil
+		},
+	})
+	commands.register(&command{
+		name:    "unalias",
+		usage:   "NAME",
+		help:    "remove the alias NAME",
+		minArgs: 1,
+		maxArgs: 1,
+		complete: func(args []string) []string {
+			var names []string
+			for name := range commands.aliases {
+				names = append(names, name)
+			}
+			return names
+		},
+		run: func(args []string) (string, error) {
+			if _, ok := commands.aliases[args[0]]; !ok {
+				return "", fmt.Errorf("no alias %q", args[0])
+			}
+			delete(commands.aliases, args[0])
+			return "removed alias " + args[0], nil
+		},
+	})
+	commands.register(&command{
+		name:    "help",
+		usage:   "[COMMAND]",
+		help:    "show the keys and commands, or how to use COMMAND",
+		maxArgs: 1,
+		complete: func(args []string) []string {
+			if len(args) > 0 {
+				return nil
+			}
+			return commands.order
+		},
+		run: func(args []string) (string, error) {
+			if len(args) == 0 {
+				showHelp()
+				return "", nil
+			}
+			c, ok := commands.commands[args[0]]
+			if !ok {
+				return "", fmt.Errorf("no command %q", args[0])
+			}
+			return c.usageLine() + ": " + c.help, nil
+		},
+	})
+	commands.register(&command{
+		name: "quit",
+		help: "quit logsync",
+		run: func(args []string) (string, error) {
+			app.Stop()
+			return "", nil
+		},
+	})
+
+	for name, line := range config.Aliases {
+		if err := commands.setAlias(name, line); err != nil {
+			showError(err)
+		}
+	}
+	for _, w := range config.Watches {
+		if err := watches.add(w); err != nil {
+			showError(err)
+		}
+	}
 
-	mainFlex = mainFlex.AddItem(flexRows, 0, 1, false)
+	history := loadCommandHistory(defaultHistoryFile())
+
+	// The up and down keys in the command box go through the history,
+	// and Tab completes the word before the cursor.
+	inputField.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyUp:
+			inputField.SetText(history.prev(inputField.GetText()))
+		case tcell.KeyDown:
+			inputField.SetText(history.next())
+		case tcell.KeyTab:
+			completed, candidates := commands.complete(inputField.GetText())
+			inputField.SetText(completed)
+			if len(candidates) > 1 {
+				messageView.SetText(tview.Escape(strings.Join(candidates, "  ")))
+			}
+		default:
+			return event
+		}
+		return nil
+	})
+
+	inputField.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			focusPane(1)
+		} else if key == tcell.KeyEnter {
+			messageView.Clear()
+			historyErr := history.add(currCommand)
+			message, err := commands.execute(currCommand)
+			if err != nil {
+				showError(err)
+			} else if historyErr != nil {
+				showError(historyErr)
+			} else {
+				messageView.SetText(tview.Escape(message))
+			}
+		}
+	})
+
+	mainFlex = mainFlex.AddItem(viewPages, 0, 1, false)
+	mainFlex = mainFlex.AddItem(watchPanel, watchPanel.height(), 0, false)
+	timeline := newTimeline(app, fileViews)
+	timeline.beforeJump = func() {
+		nav.record(fileViews)
+	}
+	mainFlex = mainFlex.AddItem(timeline, timelineHeight(len(fileViews)), 1, false)
+	mainFlex = mainFlex.AddItem(newStatusBar(fileViews), 1, 1, false)
+	mainFlex = mainFlex.AddItem(messageView, 1, 1, false)
 	mainFlex = mainFlex.AddItem(inputField, 1, 1, true)
 
+	rootPages.
+		AddPage("main", mainFlex, true, true).
+		AddPage("help", helpFlex, true, false)
+
+	// Keys typed into the command box go to it, and all the other keys go
+	// through the keymap first. While the help is shown, Esc, q and the keys
+	// that show the help close it and the other keys scroll it.
+	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if running {
+			if event.Key() == tcell.KeyEscape {
+				cancelRun = true
+			}
+			return nil
+		}
+		if frontPage, _ := rootPages.GetFrontPage(); frontPage == "help" {
+			name := keyName(event)
+			if name == "Esc" || name == "q" || km.bindings[name] == "help" {
+				rootPages.HidePage("help")
+				app.SetFocus(focusBeforeHelp)
+				return nil
+			}
+			return event
+		}
+		if app.GetFocus() == inputField {
+			return event
+		}
+		action, consumed := km.handleKey(event)
+		if actionFunc, ok := keyActionFuncs[action]; ok {
+			actionFunc()
+		}
+		if consumed {
+			return nil
+		}
+		return event
+	})
+
 	MoveAllToBeginning(fileViews)
-	if err := app.SetRoot(mainFlex, true).EnableMouse(true).Run(); err != nil {
+	if session.filename != "" {
+		for _, err := range restoreSession(session, fileViews, layout, marks, commands, watches, clockOffsets) {
+			showError(err)
+		}
+		resizeWatchPanel()
+		rearrange()
+		if session.View != "" {
+			switchView(session.View)
+		}
+		if len(session.changed) > 0 {
+			showError(fmt.Errorf("changed since the session was saved: %s", strings.Join(session.changed, ", ")))
+		}
+	}
+
+	if err := app.SetRoot(rootPages, true).EnableMouse(true).Run(); err != nil {
 		panic(err)
 	}
 }
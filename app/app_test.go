@@ -0,0 +1,132 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// openFileViews opens the short test logs in file views, like RunLogSync
+// does, and moves them to the beginning
+func openFileViews(logFilenames ...string) []fileView {
+	var fileViews []fileView
+	for i, logFilename := range logFilenames {
+		file, err := os.Open("../test_data/short-logs/" + logFilename)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fileViews = append(fileViews, *newFileView(file, logFilename, i))
+	}
+	for i := range fileViews {
+		fileViews[i].allFileViews = fileViews
+		fileViews[i].LoadInputHandler()
+	}
+	MoveAllToBeginning(fileViews)
+	return fileViews
+}
+
+// openLogFileViews writes the logs to files in a temporary directory and
+// opens them like openFileViews does. The directory is removed with
+// os.RemoveAll(filepath.Dir(fileViews[0].logFilename)).
+func openLogFileViews(logs ...string) []fileView {
+	dir, err := ioutil.TempDir("", "logsync")
+	if err != nil {
+		log.Fatal(err)
+	}
+	var fileViews []fileView
+	for i, logText := range logs {
+		logFilename := filepath.Join(dir, fmt.Sprintf("node%d.log", i))
+		if err := ioutil.WriteFile(logFilename, []byte(logText), 0644); err != nil {
+			log.Fatal(err)
+		}
+		file, err := os.Open(logFilename)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fileViews = append(fileViews, *newFileView(file, logFilename, i))
+	}
+	for i := range fileViews {
+		fileViews[i].allFileViews = fileViews
+		fileViews[i].LoadInputHandler()
+	}
+	MoveAllToBeginning(fileViews)
+	return fileViews
+}
+
+func ExampleStepAllByDuration() {
+	fileViews := openLogFileViews(
+		"I[2020-05-25|08:45:30.000] a\n"+
+			"I[2020-05-25|08:45:31.000] b\n"+
+			"I[2020-05-25|08:45:40.000] c\n",
+		"I[2020-05-25|08:45:30.500] a\n"+
+			"I[2020-05-25|08:45:41.000] b\n",
+	)
+	defer os.RemoveAll(filepath.Dir(fileViews[0].logFilename))
+
+	// The second step starts in a silence of both files that is longer
+	// than the step, so it moves to the next line of any file
+	for step := 0; step < 3; step++ {
+		StepAllByDuration(fileViews, time.Second)
+		fmt.Println(time.Unix(0, fileViews[0].currChunk.LineTimeStamp).UTC().Format(markTimeFormat),
+			time.Unix(0, fileViews[1].currChunk.LineTimeStamp).UTC().Format(markTimeFormat))
+	}
+
+	// Output: 08:45:31.000 08:45:30.500
+	// 08:45:40.000 08:45:30.500
+	// 08:45:40.000 08:45:41.000
+}
+
+func Example_focusedFileView() {
+	fileViews := openFileViews("node0-json.log", "node1-json.log")
+	flex := tview.NewFlex().SetDirection(tview.FlexRow)
+	for i := range fileViews {
+		flex.AddItem(&fileViews[i], 0, 1, false)
+	}
+
+	screen := tcell.NewSimulationScreen("")
+	screen.Init()
+	screen.SetSize(120, 40)
+	app := tview.NewApplication().SetScreen(screen).SetRoot(flex, true)
+	flex.SetRect(0, 0, 120, 40)
+	flex.Draw(screen)
+
+	// Clicking a line of the second pane selects it and focuses the pane
+	flex.MouseHandler()(tview.MouseLeftClick, tcell.NewEventMouse(10, 23, tcell.Button1, 0), func(p tview.Primitive) {
+		app.SetFocus(p)
+	})
+	index := focusedFileView(fileViews, app.GetFocus())
+	fmt.Println("focused", index, "line", fileViews[1].lineNumber())
+
+	// The down key then selects the next line of the focused pane
+	km, _ := newKeymap("", nil)
+	if action, _ := km.handleKey(tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone)); action == "select-next-line" && index > -1 {
+		fileViews[index].selectAdjacentLine(true)
+	}
+	fmt.Println("focused", index, "line", fileViews[1].lineNumber())
+
+	// Output: focused 1 line 2
+	// focused 1 line 3
+}
+
+func Example_lastMovedIndex() {
+	fileViews := openFileViews("node0-json.log", "node1-json.log")
+	otherFileViews := openFileViews("node2-json.log", "node3-json.log", "node0-json.log")
+	fmt.Println(lastMovedIndex(fileViews), lastMovedIndex(otherFileViews))
+
+	// Each set of file views keeps track of its own last moved file
+	SyncAllToFileChunk(otherFileViews, 2, otherFileViews[2].currChunk.GetNextFileChunk())
+	AdvanceNextFileViewForward(fileViews)
+	fmt.Println(lastMovedIndex(fileViews) > -1, lastMovedIndex(otherFileViews))
+	moved := AdvancePrevFileViewBackward(fileViews)
+	fmt.Println(lastMovedIndex(fileViews) == moved, lastMovedIndex(otherFileViews))
+
+	// Output: -1 -1
+	// true 2
+	// true 2
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultHistoryName is the name of the file in the home directory
+// where the commands typed in the command box are kept
+const defaultHistoryName = ".logsync_history"
+
+// maxCommandHistory is how many commands are kept in the history
+const maxCommandHistory = 1000
+
+// commandHistory is the list of commands typed in the command box, oldest
+// first, which can be browsed with the up and down keys. It is kept in a
+// file so it is still there the next time logsync runs.
+type commandHistory struct {
+	entries  []string // The commands typed, oldest first
+	position int      // The entry being browsed, or len(entries) when not browsing
+	draft    string   // What was typed before browsing started
+	filename string   // The file the history is kept in, or empty to not keep it
+	failed   bool     // Whether writing the file has failed, which is only reported once
+}
+
+// defaultHistoryFile returns the history file in the home directory,
+// or an empty string if there is no home directory.
+func defaultHistoryFile() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, defaultHistoryName)
+}
+
+// loadCommandHistory reads the history from the file. A missing
+// or unreadable file is an empty history.
+func loadCommandHistory(filename string) *commandHistory {
+	ch := &commandHistory{filename: filename}
+	if filename != "" {
+		if file, err := os.Open(filename); err == nil {
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				if line := scanner.Text(); line != "" {
+					ch.entries = append(ch.entries, line)
+				}
+			}
+			file.Close()
+		}
+	}
+	if len(ch.entries) > maxCommandHistory {
+		ch.entries = ch.entries[len(ch.entries)-maxCommandHistory:]
+	}
+	ch.position = len(ch.entries)
+	return ch
+}
+
+// add puts the command at the end of the history, unless it is the same as
+// the last one, and writes the history to its file. It returns the error the
+// first time the file cannot be written, and nil after that, so that the
+// problem is only shown once.
+func (ch *commandHistory) add(line string) error {
+	var err error
+	line = strings.TrimSpace(line)
+	if line != "" && (len(ch.entries) == 0 || ch.entries[len(ch.entries)-1] != line) {
+		ch.entries = append(ch.entries, line)
+		if len(ch.entries) > maxCommandHistory {
+			ch.entries = ch.entries[1:]
+		}
+		if ch.filename != "" {
+			if writeErr := ioutil.WriteFile(ch.filename, []byte(strings.Join(ch.entries, "\n")+"\n"), 0600); writeErr != nil && !ch.failed {
+				ch.failed = true
+				err = fmt.Errorf("the command history cannot be saved: %v", writeErr)
+			}
+		}
+	}
+	ch.position = len(ch.entries)
+	ch.draft = ""
+	return err
+}
+
+// prev returns the command before the one being browsed. The current line is
+// remembered when browsing starts so that next can come back to it.
+func (ch *commandHistory) prev(current string) string {
+	if ch.position == len(ch.entries) {
+		ch.draft = current
+	}
+	if ch.position > 0 {
+		ch.position--
+	}
+	if ch.position == len(ch.entries) {
+		return ch.draft
+	}
+	return ch.entries[ch.position]
+}
+
+// next returns the command after the one being browsed, or the line
+// that was being typed when browsing started.
+func (ch *commandHistory) next() string {
+	if ch.position < len(ch.entries) {
+		ch.position++
+	}
+	if ch.position == len(ch.entries) {
+		return ch.draft
+	}
+	return ch.entries[ch.position]
+}
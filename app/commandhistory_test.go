@@ -0,0 +1,44 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func Example_commandHistory() {
+	dir, err := ioutil.TempDir("", "logsync")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, defaultHistoryName)
+
+	history := loadCommandHistory(filename)
+	for _, line := range []string{"mark start", " +5s ", "+5s", "", "/height=12"} {
+		fmt.Println(history.add(line))
+	}
+
+	// The history is read back the next time, and browsing it comes back
+	// to what was being typed
+	history = loadCommandHistory(filename)
+	fmt.Println(history.entries)
+	fmt.Printf("%q %q %q %q\n", history.prev("tail"), history.prev(""), history.prev(""), history.prev(""))
+	fmt.Printf("%q %q %q %q\n", history.next(), history.next(), history.next(), history.next())
+
+	// A history that cannot be written says so once
+	history = loadCommandHistory(filepath.Join(dir, "missing", defaultHistoryName))
+	fmt.Println(history.add("head") != nil, history.add("tail"))
+
+	// Output: <nil>
+	// <nil>
+	// <nil>
+	// <nil>
+	// <nil>
+	// [mark start +5s /height=12]
+	// "/height=12" "+5s" "mark start" "mark start"
+	// "+5s" "/height=12" "tail" "tail"
+	// true <nil>
+}
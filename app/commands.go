@@ -0,0 +1,304 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// command is one of the commands that can be typed in the command box.
+// The first word of the line is the name of the command and the rest
+// of the words are its arguments.
+type command struct {
+	name     string                              // The first word of the command
+	usage    string                              // How the arguments are written, like "N W"
+	help     string                              // What the command does
+	minArgs  int                                 // The fewest arguments the command takes
+	maxArgs  int                                 // The most arguments the command takes, or -1 for any number
+	complete func(args []string) []string        // Returns the candidates for the argument after args, or nil
+	run      func(args []string) (string, error) // Runs the command and returns a message to show
+}
+
+// usageLine returns how the command is written, like "weight N W"
+func (c *command) usageLine() string {
+	if c.usage == "" {
+		return c.name
+	}
+	return c.name + " " + c.usage
+}
+
+// commandRegistry holds all the commands that can be typed in the command box
+// along with the aliases the user has defined for them. Lines that do not
+// start with the name of a command or alias are handled by the fallback,
+// which is where step counts, durations, searches and times are handled.
+type commandRegistry struct {
+	commands map[string]*command               // The commands by name
+	aliases  map[string]string                 // The line each alias stands for, by alias name
+	fallback func(line string) (string, error) // Runs a line that does not start with a command
+	words    func() []string                   // Returns the words a search can be completed with
+	order    []string                          // The names of the commands in the order they were registered
+}
+
+// newCommandRegistry creates an empty registry
+func newCommandRegistry() *commandRegistry {
+	return &commandRegistry{
+		commands: make(map[string]*command),
+		aliases:  make(map[string]string),
+	}
+}
+
+// register adds the command to the registry
+func (cr *commandRegistry) register(c *command) {
+	if _, ok := cr.commands[c.name]; !ok {
+		cr.order = append(cr.order, c.name)
+	}
+	cr.commands[c.name] = c
+}
+
+// setAlias makes the name stand for the line, so that typing the name
+// followed by more arguments runs the line with those arguments added.
+func (cr *commandRegistry) setAlias(name string, line string) error {
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("bad alias name %q", name)
+	}
+	if _, ok := cr.commands[name]; ok {
+		return fmt.Errorf("%q is already a command", name)
+	}
+	if strings.TrimSpace(line) == "" {
+		return fmt.Errorf("alias %s needs a command to stand for", name)
+	}
+	cr.aliases[name] = strings.TrimSpace(line)
+	return nil
+}
+
+// expandAlias replaces an alias at the start of the line with the line it
+// stands for. Aliases are only expanded once, so an alias can not loop.
+func (cr *commandRegistry) expandAlias(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return line
+	}
+	expansion, ok := cr.aliases[fields[0]]
+	if !ok {
+		return line
+	}
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), fields[0]))
+	if rest == "" {
+		return expansion
+	}
+	return expansion + " " + rest
+}
+
+// execute runs the line typed in the command box and returns the message
+// to show for it. Lines that are not understood return an error rather
+// than being ignored.
+func (cr *commandRegistry) execute(line string) (string, error) {
+	line = cr.expandAlias(strings.TrimSpace(line))
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", nil
+	}
+
+	c, ok := cr.commands[fields[0]]
+	if !ok {
+		return cr.fallback(line)
+	}
+	args := fields[1:]
+	if len(args) < c.minArgs || (c.maxArgs >= 0 && len(args) > c.maxArgs) {
+		return "", fmt.Errorf("usage: %s", c.usageLine())
+	}
+	return c.run(args)
+}
+
+// commandNames returns the names of all the commands and aliases, sorted
+func (cr *commandRegistry) commandNames() []string {
+	var names []string
+	for name := range cr.commands {
+		names = append(names, name)
+	}
+	for name := range cr.aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// helpText lists every command with what it does, followed by the aliases
+func (cr *commandRegistry) helpText() string {
+	var help strings.Builder
+	help.WriteString("commands:\n\n")
+	for _, name := range cr.order {
+		c := cr.commands[name]
+		fmt.Fprintf(&help, "%-26s %s\n", c.usageLine(), c.help)
+	}
+	help.WriteString("\nA number steps that many lines, \"+5s\" steps in time, \"/text\" searches\n")
+	help.WriteString("and anything else is read as a time to jump to.\n")
+
+	if len(cr.aliases) > 0 {
+		help.WriteString("\naliases:\n\n")
+		var names []string
+		for name := range cr.aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&help, "%-26s %s\n", name, cr.aliases[name])
+		}
+	}
+	return help.String()
+}
+
+// withPrefix returns the candidates that start with the prefix
+func withPrefix(candidates []string, prefix string) []string {
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+// commonPrefix returns the longest prefix that all the words share
+func commonPrefix(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// complete completes the last word of the line. The word is completed as far
+// as all the candidates agree, and when there is only one candidate a space
+// is added after it so the next argument can be typed. It returns the
+// completed line and the candidates, so they can be shown when there is
+// more than one.
+func (cr *commandRegistry) complete(line string) (string, []string) {
+	fields := strings.Fields(line)
+	endsWord := len(fields) > 0 && !strings.HasSuffix(line, " ")
+
+	var word string
+	if endsWord {
+		word = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+
+	var candidates []string
+	wordPrefix := ""
+	switch {
+	case strings.HasPrefix(line, "/"):
+		// Searches complete the field names seen in the log lines
+		if len(fields) == 0 {
+			wordPrefix = "/"
+			word = strings.TrimPrefix(word, "/")
+		}
+		if cr.words != nil {
+			candidates = withPrefix(cr.words(), word)
+		}
+	case len(fields) == 0:
+		candidates = withPrefix(cr.commandNames(), word)
+	default:
+		c, ok := cr.commands[fields[0]]
+		if ok && c.complete != nil {
+			candidates = withPrefix(c.complete(fields[1:]), word)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return line, nil
+	}
+	completed := commonPrefix(candidates)
+	if len(candidates) == 1 && !strings.HasSuffix(completed, "=") {
+		completed += " "
+	}
+	return line[:len(line)-len(wordPrefix)-len(word)] + wordPrefix + completed, candidates
+}
+
+// fieldPattern matches the key=value fields in log lines, like "height=12"
+var fieldPattern = regexp.MustCompile(`\b([A-Za-z_][A-Za-z0-9_.]*)=`)
+
+// fieldNames returns the names of the key=value fields in the lines shown in
+// the file views, like "height=", sorted and without duplicates.
+func fieldNames(fileViews []fileView) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for i := range fileViews {
+		for _, chunk := range fileViews[i].displayChunks {
+			for _, match := range fieldPattern.FindAllSubmatch(chunk.FileChunkBytes, -1) {
+				name := string(match[1]) + "="
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// logFilenames returns the file name of every file view as it was given
+func logFilenames(fileViews []fileView) []string {
+	names := make([]string, len(fileViews))
+	for i := range fileViews {
+		names[i] = fileViews[i].logFilename
+	}
+	return names
+}
+
+// uniqueNames returns the name that each file is shown with in the lists
+// and reports and that can be used instead of its pane number in commands.
+// That is the file name without its directory, or with as many of its
+// directories as it takes to tell it apart from the other files, like
+// node0/app.log and node1/app.log.
+func uniqueNames(logFilenames []string) []string {
+	paths := make([][]string, len(logFilenames))
+	for i, logFilename := range logFilenames {
+		paths[i] = strings.Split(filepath.ToSlash(filepath.Clean(logFilename)), "/")
+	}
+	// suffix returns the last n parts of the path, or nil if it has fewer
+	suffix := func(path []string, n int) []string {
+		if n > len(path) {
+			return nil
+		}
+		return path[len(path)-n:]
+	}
+
+	names := make([]string, len(logFilenames))
+	for i, path := range paths {
+		n := 1
+		for ; n < len(path); n++ {
+			unique := true
+			for j, other := range paths {
+				if j != i && strings.Join(suffix(other, n), "/") == strings.Join(suffix(path, n), "/") {
+					unique = false
+					break
+				}
+			}
+			if unique {
+				break
+			}
+		}
+		names[i] = filepath.FromSlash(strings.Join(suffix(path, n), "/"))
+	}
+	return names
+}
+
+// resolvePaneName returns the pane number of the file view whose file name
+// is arg, or arg itself if it is not the name of a file.
+func resolvePaneName(fileViews []fileView, arg string) string {
+	for i, name := range uniqueNames(logFilenames(fileViews)) {
+		if arg == name || arg == fileViews[i].logFilename {
+			return strconv.Itoa(i + 1)
+		}
+	}
+	return arg
+}
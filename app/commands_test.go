@@ -0,0 +1,102 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+)
+
+// exampleRegistry returns a registry with a few commands that print what
+// they were run with
+func exampleRegistry() *commandRegistry {
+	cr := newCommandRegistry()
+	for _, name := range []string{"mark", "marks", "measure", "weight"} {
+		name := name
+		cr.register(&command{
+			name:    name,
+			maxArgs: 2,
+			complete: func(args []string) []string {
+				if len(args) == 0 {
+					return []string{"start", "stop", "timeout"}
+				}
+				return nil
+			},
+			run: func(args []string) (string, error) {
+				return name + " " + strings.Join(args, ","), nil
+			},
+		})
+	}
+	cr.fallback = func(line string) (string, error) {
+		return "", fmt.Errorf("not a command: %s", line)
+	}
+	cr.words = func() []string { return []string{"hash=", "height="} }
+	return cr
+}
+
+func Example_commandRegistry_expandAlias() {
+	cr := exampleRegistry()
+	fmt.Println(cr.setAlias("m", "mark start"))
+	fmt.Println(cr.setAlias("marks", "mark"))
+	fmt.Println(cr.setAlias("two words", "mark"))
+
+	fmt.Println(cr.expandAlias("m"))
+	fmt.Println(cr.expandAlias("  m   stop  "))
+	fmt.Println(cr.expandAlias("mark m"))
+	fmt.Println(cr.execute("m stop"))
+	fmt.Println(cr.execute("m stop extra"))
+	fmt.Println(cr.execute("nothing"))
+
+	// Output: <nil>
+	// "marks" is already a command
+	// bad alias name "two words"
+	// mark start
+	// mark start stop
+	// mark m
+	// mark start,stop <nil>
+	//  usage: mark
+	//  not a command: nothing
+}
+
+func Example_commandRegistry_complete() {
+	cr := exampleRegistry()
+	cr.setAlias("mm", "measure")
+	for _, line := range []string{"we", "ma", "m", "mark ", "mark t", "mark stop ", "/he", "/h", "zzz"} {
+		completed, candidates := cr.complete(line)
+		fmt.Printf("%q %v\n", completed, candidates)
+	}
+
+	// Output: "weight " [weight]
+	// "mark" [mark marks]
+	// "m" [mark marks measure mm]
+	// "mark " [start stop timeout]
+	// "mark timeout " [timeout]
+	// "mark stop " []
+	// "/height=" [height=]
+	// "/h" [hash= height=]
+	// "zzz" []
+}
+
+func Example_uniqueNames() {
+	logFilenames := []string{"logs/node0/app.log", "logs/node1/app.log", "logs/node1/p2p.log", "p2p.log", "logs/node2/db.log"}
+	names := uniqueNames(logFilenames)
+	fmt.Println(strings.Join(names, " "))
+
+	fileViews := make([]fileView, len(logFilenames))
+	for i := range logFilenames {
+		fileViews[i].logFilename = logFilenames[i]
+	}
+	for _, ref := range []string{"node0/app", "app", "db", "node1/p2p.log", "p2p.log", "p2p", "node", "#2", "node3"} {
+		index, err := resolveFileRef(fileViews, ref)
+		fmt.Println(ref, index, err)
+	}
+
+	// Output: node0/app.log node1/app.log node1/p2p.log p2p.log db.log
+	// node0/app 0 <nil>
+	// app -1 "app" could be more than one file, like node0/app.log and node1/app.log
+	// db 4 <nil>
+	// node1/p2p.log 2 <nil>
+	// p2p.log 3 <nil>
+	// p2p 3 <nil>
+	// node -1 "node" could be more than one file, like node0/app.log and node1/app.log
+	// #2 1 <nil>
+	// node3 -1 no file "node3", expected a file name or #N
+}
@@ -0,0 +1,528 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// condContext is what a condition is checked against: the files at their
+// current lines, and the line that the last step moved to
+type condContext struct {
+	fileViews []fileView
+	lineIndex int                  // The index of the file that moved last
+	line      *filechunk.FileChunk // The line that the last step moved to
+}
+
+// condition is a true or false test of the current position, like
+// "node0.height - node2.height > 2 or line ~ timeout"
+type condition interface {
+	test(ctx *condContext) bool
+}
+
+// condValue is one side of a comparison in a condition
+type condValue interface {
+	value(ctx *condContext) string
+}
+
+// andCondition is true when both conditions are
+type andCondition struct{ left, right condition }
+
+func (c andCondition) test(ctx *condContext) bool { return c.left.test(ctx) && c.right.test(ctx) }
+
+// orCondition is true when either condition is
+type orCondition struct{ left, right condition }
+
+func (c orCondition) test(ctx *condContext) bool { return c.left.test(ctx) || c.right.test(ctx) }
+
+// notCondition is true when the condition is not
+type notCondition struct{ cond condition }
+
+func (c notCondition) test(ctx *condContext) bool { return !c.cond.test(ctx) }
+
+// compareCondition compares two values, as numbers if they both are and as
+// text otherwise. The ~ and !~ operators match the left value against the
+// regular expression on the right.
+type compareCondition struct {
+	left, right condValue
+	op          string
+	pattern     *regexp.Regexp // The regular expression of ~ and !~
+}
+
+func (c compareCondition) test(ctx *condContext) bool {
+	left := c.left.value(ctx)
+	switch c.op {
+	case "~":
+		return c.pattern.MatchString(left)
+	case "!~":
+		return !c.pattern.MatchString(left)
+	}
+
+	right := c.right.value(ctx)
+	leftNumber, leftErr := strconv.ParseFloat(left, 64)
+	rightNumber, rightErr := strconv.ParseFloat(right, 64)
+	if leftErr == nil && rightErr == nil {
+		switch c.op {
+		case "==":
+			return leftNumber == rightNumber
+		case "!=":
+			return leftNumber != rightNumber
+		case "<":
+			return leftNumber < rightNumber
+		case "<=":
+			return leftNumber <= rightNumber
+		case ">":
+			return leftNumber > rightNumber
+		case ">=":
+			return leftNumber >= rightNumber
+		}
+	}
+
+	switch c.op {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	}
+	// Text that is not a number, like a watch that has no value yet,
+	// is neither more nor less than anything
+	if left == "" || right == "" {
+		return false
+	}
+	switch c.op {
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	case ">=":
+		return left >= right
+	}
+	return false
+}
+
+// literalValue is a number or text written in the condition
+type literalValue string
+
+func (v literalValue) value(ctx *condContext) string { return string(v) }
+
+// lineValue is the text of the line the last step moved to
+type lineValue struct{}
+
+func (v lineValue) value(ctx *condContext) string { return string(ctx.line.FileChunkBytes) }
+
+// fileValue is the name of the file that the last step moved in
+type fileValue struct {
+	names []string // The names of all the files, as uniqueNames gives them
+}
+
+func (v fileValue) value(ctx *condContext) string {
+	return v.names[ctx.lineIndex]
+}
+
+// watchRef is the value of a watch in one file, or the largest or smallest
+// value of the watch across all the files
+type watchRef struct {
+	fileIndex int    // The index of the file, or -1 for max and min
+	aggregate string // "max" or "min" when fileIndex is -1
+	w         *watch
+}
+
+func (v watchRef) value(ctx *condContext) string {
+	if v.fileIndex >= 0 {
+		return v.w.valueAt(v.fileIndex, ctx.fileViews[v.fileIndex].currChunk)
+	}
+
+	result := math.NaN()
+	for i := range ctx.fileViews {
+		number, err := strconv.ParseFloat(v.w.valueAt(i, ctx.fileViews[i].currChunk), 64)
+		if err != nil {
+			continue
+		}
+		if math.IsNaN(result) || (v.aggregate == "max" && number > result) || (v.aggregate == "min" && number < result) {
+			result = number
+		}
+	}
+	if math.IsNaN(result) {
+		return ""
+	}
+	return strconv.FormatFloat(result, 'f', -1, 64)
+}
+
+// sumValue adds and subtracts numbers, and is empty if any of them is not
+// a number
+type sumValue struct {
+	terms []condValue
+	signs []float64
+}
+
+func (v sumValue) value(ctx *condContext) string {
+	var sum float64
+	for i, term := range v.terms {
+		number, err := strconv.ParseFloat(term.value(ctx), 64)
+		if err != nil {
+			return ""
+		}
+		sum += v.signs[i] * number
+	}
+	return strconv.FormatFloat(sum, 'f', -1, 64)
+}
+
+// compareOperators are the operators that compare two values
+var compareOperators = map[string]bool{
+	"==": true, "=": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true, "~": true, "!~": true,
+}
+
+// patternEnd returns where the regular expression that starts at start in
+// the condition ends: at the first " and " or " or " outside of its groups
+// and character classes, at a ) that closes a group of the condition, or
+// at the end of the text
+func patternEnd(text string, start int) int {
+	depth := 0
+	inClass := false
+	for i := start; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\\':
+			i++
+		case inClass:
+			inClass = c != ']'
+		case c == '[':
+			inClass = true
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case depth == 0 && (strings.HasPrefix(text[i:], " and ") || strings.HasPrefix(text[i:], " or ")):
+			return i
+		}
+	}
+	return len(text)
+}
+
+// tokenizeCondition splits a condition into words, quoted text,
+// parentheses and comparison operators. The regular expression after ~ or
+// !~ is one token: the quoted text if it starts with a quote, and
+// otherwise the rest of the comparison, so that it can have spaces and
+// parentheses without quotes as in line ~ (timeout|panic).
+func tokenizeCondition(text string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			end := strings.IndexByte(text[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("missing closing quote in %s", text[i:])
+			}
+			tokens = append(tokens, text[i:i+end+2])
+			i += end + 2
+		case strings.IndexByte("<>=!~", c) >= 0:
+			end := i
+			for end < len(text) && strings.IndexByte("<>=!~", text[end]) >= 0 {
+				end++
+			}
+			op := text[i:end]
+			tokens = append(tokens, op)
+			i = end
+			if op == "~" || op == "!~" {
+				for i < len(text) && (text[i] == ' ' || text[i] == '\t') {
+					i++
+				}
+				if i < len(text) && text[i] != '"' {
+					end = patternEnd(text, i)
+					if pattern := strings.TrimRight(text[i:end], " \t"); pattern != "" {
+						tokens = append(tokens, pattern)
+					}
+					i = end
+				}
+			}
+		default:
+			end := i
+			for end < len(text) && strings.IndexByte(" \t()\"<>=!~", text[end]) < 0 {
+				end++
+			}
+			tokens = append(tokens, text[i:end])
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// conditionParser parses a condition with recursive descent
+type conditionParser struct {
+	tokens    []string
+	pos       int
+	fileViews []fileView
+	watches   *watchList
+	implicit  map[string]*watch // Watches of fields that are not in the watch list
+}
+
+// parseCondition parses a condition. A condition compares values with
+// ==, !=, <, <=, >, >=, ~ (matches a regular expression) and !~, and
+// combines comparisons with and, or, not and parentheses. The values are:
+//
+//	FILE.NAME  the value of watch NAME in the file, like node2.height or #3.height
+//	max.NAME   the largest number value of watch NAME in any file
+//	min.NAME   the smallest number value of watch NAME in any file
+//	line       the line that the last step moved to
+//	file       the name of the file the last step moved in
+//
+// and numbers and text, which is quoted if it has spaces. Values can be
+// added and subtracted with + and - written between spaces. NAME is a watch
+// from the watch list, or otherwise the field with that name.
+func parseCondition(text string, fileViews []fileView, watches *watchList) (condition, error) {
+	tokens, err := tokenizeCondition(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("the condition is empty")
+	}
+	p := &conditionParser{
+		tokens:    tokens,
+		fileViews: fileViews,
+		watches:   watches,
+		implicit:  make(map[string]*watch),
+	}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in the condition", p.tokens[p.pos])
+	}
+	return cond, nil
+}
+
+// peek returns the next token, or an empty string at the end
+func (p *conditionParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// next returns the next token and moves past it
+func (p *conditionParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *conditionParser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.peek() == "or" {
+		p.next()
+		var right condition
+		if right, err = p.parseAnd(); err == nil {
+			left = orCondition{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *conditionParser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	for err == nil && p.peek() == "and" {
+		p.next()
+		var right condition
+		if right, err = p.parseNot(); err == nil {
+			left = andCondition{left, right}
+		}
+	}
+	return left, err
+}
+
+func (p *conditionParser) parseNot() (condition, error) {
+	switch p.peek() {
+	case "not":
+		p.next()
+		cond, err := p.parseNot()
+		return notCondition{cond}, err
+	case "(":
+		p.next()
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing ) in the condition")
+		}
+		return cond, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *conditionParser) parseComparison() (condition, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	if !compareOperators[op] {
+		if op == "" {
+			return nil, fmt.Errorf("the condition ends without a comparison like == or ~")
+		}
+		return nil, fmt.Errorf("expected a comparison like == or ~ instead of %q", op)
+	}
+	if op == "=" {
+		op = "=="
+	}
+
+	cond := compareCondition{left: left, op: op}
+	if op == "~" || op == "!~" {
+		token := p.next()
+		if token == "" {
+			return nil, fmt.Errorf("expected a regular expression after %s", op)
+		}
+		if cond.pattern, err = regexp.Compile(unquote(token)); err != nil {
+			return nil, fmt.Errorf("bad regular expression %s: %v", token, err)
+		}
+		return cond, nil
+	}
+	cond.right, err = p.parseSum()
+	return cond, err
+}
+
+func (p *conditionParser) parseSum() (condValue, error) {
+	term, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	sum := sumValue{terms: []condValue{term}, signs: []float64{1}}
+	for p.peek() == "+" || p.peek() == "-" {
+		sign := 1.0
+		if p.next() == "-" {
+			sign = -1
+		}
+		if term, err = p.parseValue(); err != nil {
+			return nil, err
+		}
+		sum.terms = append(sum.terms, term)
+		sum.signs = append(sum.signs, sign)
+	}
+	if len(sum.terms) == 1 {
+		return term, nil
+	}
+	return sum, nil
+}
+
+func (p *conditionParser) parseValue() (condValue, error) {
+	token := p.next()
+	switch {
+	case token == "" || token == ")" || compareOperators[token]:
+		return nil, fmt.Errorf("expected a value in the condition")
+	case strings.HasPrefix(token, `"`):
+		return literalValue(unquote(token)), nil
+	case token == "line":
+		return lineValue{}, nil
+	case token == "file":
+		return fileValue{names: uniqueNames(logFilenames(p.fileViews))}, nil
+	}
+	if _, err := strconv.ParseFloat(token, 64); err == nil {
+		return literalValue(token), nil
+	}
+
+	dot := strings.LastIndexByte(token, '.')
+	if dot < 0 {
+		return literalValue(token), nil
+	}
+	fileRef, name := token[:dot], token[dot+1:]
+	w, err := p.watch(name)
+	if err != nil {
+		return nil, err
+	}
+	if fileRef == "max" || fileRef == "min" {
+		return watchRef{fileIndex: -1, aggregate: fileRef, w: w}, nil
+	}
+	fileIndex, err := resolveFileRef(p.fileViews, fileRef)
+	if err != nil {
+		return nil, err
+	}
+	return watchRef{fileIndex: fileIndex, w: w}, nil
+}
+
+// watch returns the watch with the name from the watch list, or a watch of
+// the field with that name
+func (p *conditionParser) watch(name string) (*watch, error) {
+	for _, w := range p.watches.watches {
+		if w.Name == name {
+			return w, nil
+		}
+	}
+	if w, ok := p.implicit[name]; ok {
+		return w, nil
+	}
+	w := &watch{Name: name}
+	if err := w.compile(); err != nil {
+		return nil, err
+	}
+	p.implicit[name] = w
+	return w, nil
+}
+
+// unquote removes the quotes around quoted text in a condition
+func unquote(token string) string {
+	if len(token) >= 2 && strings.HasPrefix(token, `"`) && strings.HasSuffix(token, `"`) {
+		return token[1 : len(token)-1]
+	}
+	return token
+}
+
+// resolveFileRef returns the index of the file that the reference is to.
+// A file is referred to as #N, by its name as uniqueNames gives it or its
+// file name without the directory, with or without the extension, or by
+// the start of its name. It is an error if the reference could be to more
+// than one file, like app when node0/app.log and node1/app.log are loaded.
+func resolveFileRef(fileViews []fileView, ref string) (int, error) {
+	if strings.HasPrefix(ref, "#") {
+		return parsePaneNumber(strings.TrimPrefix(ref, "#"), len(fileViews))
+	}
+	names := uniqueNames(logFilenames(fileViews))
+	baseNames := make([]string, len(fileViews))
+	for i := range fileViews {
+		baseNames[i] = filepath.Base(fileViews[i].logFilename)
+	}
+	// matching returns the indices of the files whose name, with or
+	// without the extension, matches
+	matching := func(names []string, matches func(name string) bool) []int {
+		var indices []int
+		for i, name := range names {
+			if matches(name) || matches(strings.TrimSuffix(name, filepath.Ext(name))) {
+				indices = append(indices, i)
+			}
+		}
+		return indices
+	}
+
+	isRef := func(name string) bool { return name == ref }
+	startsRef := func(name string) bool { return strings.HasPrefix(name, ref) }
+	indices := matching(names, isRef)
+	if len(indices) == 0 {
+		indices = matching(baseNames, isRef)
+	}
+	if len(indices) == 0 {
+		indices = matching(names, startsRef)
+	}
+	switch len(indices) {
+	case 0:
+		return -1, fmt.Errorf("no file %q, expected a file name or #N", ref)
+	case 1:
+		return indices[0], nil
+	}
+	return -1, fmt.Errorf("%q could be more than one file, like %s and %s", ref, names[indices[0]], names[indices[1]])
+}
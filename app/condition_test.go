@@ -0,0 +1,45 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+func Example_parseCondition() {
+	ctx := &condContext{
+		line: &filechunk.FileChunk{FileChunkBytes: []byte("I[2020-05-25|08:45:35.546] Timed out module=consensus height=3 round=0\n")},
+	}
+	for _, text := range []string{
+		"line ~ \"Timed out\"",
+		"line !~ \"(?i)timed out\"",
+		"line ~ (timeout|Timed out) and (1 > 2 or line ~ round=[)0])",
+		"line ~ Timed out module or line ~ panic",
+		"line ~ (panic",
+		"5 - 2 > 2 and not 1 == 2",
+		"(abc < abd or 1 > 2) and \"x y\" == \"x y\"",
+		"10 + 1 > 9",
+		"node0.height > 1",
+		"1 + > 2",
+		"1 2",
+	} {
+		cond, err := parseCondition(text, nil, &watchList{})
+		if err != nil {
+			fmt.Println(text, "error:", err)
+			continue
+		}
+		fmt.Println(text, cond.test(ctx))
+	}
+
+	// Output: line ~ "Timed out" true
+	// line !~ "(?i)timed out" false
+	// line ~ (timeout|Timed out) and (1 > 2 or line ~ round=[)0]) true
+	// line ~ Timed out module or line ~ panic true
+	// line ~ (panic error: bad regular expression (panic: error parsing regexp: missing closing ): `(panic`
+	// 5 - 2 > 2 and not 1 == 2 true
+	// (abc < abd or 1 > 2) and "x y" == "x y" true
+	// 10 + 1 > 9 true
+	// node0.height > 1 error: no file "node0", expected a file name or #N
+	// 1 + > 2 error: expected a value in the condition
+	// 1 2 error: expected a comparison like == or ~ instead of "2"
+}
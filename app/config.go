@@ -0,0 +1,67 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// defaultConfigName is the name of the config file in the home directory
+// that is loaded when no config file is given.
+const defaultConfigName = ".logsync.json"
+
+// Config holds the settings that can be given in the config file.
+// A config file looks like:
+//
+//	{
+//		"keymap": "vim",
+//		"keys": {"Ctrl-J": "step-forward", "Z Z": "none"},
+//		"layout": "columns",
+//		"aliases": {"boot": "/Starting", "wide": "layout columns"},
+//		"theme": "light",
+//		"colors": {"accent": "purple"},
+//		"highlights": [{"field": "module", "value": "consensus", "line": true, "fg": "aqua"}],
+//		"watches": [{"name": "height"}, {"name": "step", "match": "step=RoundStep(\\w+)"}],
+//		"clock_offsets": {"node2": "-150ms"},
+//		"restart_pattern": "Starting multiAppConn service"
+//	}
+type Config struct {
+	Keymap         string            `json:"keymap"`          // The keymap preset: default, vim or emacs
+	Keys           map[string]string `json:"keys"`            // Key sequences bound to actions on top of the preset
+	Layout         string            `json:"layout"`          // How the file views are arranged: rows, columns or grid
+	Aliases        map[string]string `json:"aliases"`         // Names that stand for commands typed in the command box
+	Theme          string            `json:"theme"`           // The color theme: dark, light or colorblind
+	Colors         map[string]string `json:"colors"`          // Colors of the theme to change, like "accent" or "nodes"
+	Highlights     []*highlightRule  `json:"highlights"`      // Rules that color the parts of log lines that match them
+	Watches        []*watch          `json:"watches"`         // Values shown for every file in the watch panel
+	ClockOffsets   map[string]string `json:"clock_offsets"`   // Durations added to the times of files whose clocks are off, for latencies
+	RestartPattern string            `json:"restart_pattern"` // The line a node logs when it starts, which begins a new run of its file
+}
+
+// LoadConfig reads the config file. If the filename is empty, the default
+// config file in the home directory is read if there is one, and otherwise
+// the default settings are used.
+func LoadConfig(filename string) (*Config, error) {
+	config := &Config{}
+	if filename == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return config, nil
+		}
+		filename = filepath.Join(homeDir, defaultConfigName)
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			return config, nil
+		}
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("bad config file %s: %v", filename, err)
+	}
+	return config, nil
+}
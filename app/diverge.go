@@ -0,0 +1,248 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// keyedValue is the first value a file has for a key, like the app hash it
+// has for a height, and the line it is on
+type keyedValue struct {
+	value  string // The value
+	offset int64  // The offset of the line the value is on
+	time   int64  // The time of the line, or of the last value before it with a time
+	line   []byte // The line the value is on, read again once the divergence is found
+}
+
+// keyedValues is every key a file has a value for and the first value it
+// has for each of them
+type keyedValues map[string]*keyedValue
+
+// divergence is the first key that the files have different values for
+type divergence struct {
+	key      string        // The key, like a height
+	values   []*keyedValue // The value of every file for the key, nil for the files without one
+	compared int           // How many keys before it more than one file had a value for
+}
+
+// newExtractor makes the watch that finds the keys or the values for
+// diverge in the log lines. It is the watch named expr if there is one,
+// else the field named expr, else expr as a regular expression.
+func newExtractor(expr string, name string, watches *watchList) (*watch, error) {
+	var w *watch
+	if watches != nil {
+		for _, existing := range watches.watches {
+			if existing.Name == expr {
+				w = &watch{Name: existing.Name, Field: existing.Field, Match: existing.Match}
+			}
+		}
+	}
+	if w == nil && fieldNamePattern.MatchString(expr) {
+		w = &watch{Name: expr, Field: expr}
+	}
+	if w == nil {
+		w = &watch{Name: name, Match: expr}
+	}
+	if err := w.compile(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// scanKeyedValues reads the whole file and finds the first value for every
+// key. A value belongs to the last key that was found at or before its line,
+// so the key and the value can be on the same line or the key can be on an
+// earlier one, like a height before the lines about it. Only the lines with
+// a value are parsed for their time, to keep the scan fast.
+func scanKeyedValues(file io.ReaderAt, key *watch, value *watch) (keyedValues, error) {
+	values := make(keyedValues)
+	reader := bufio.NewReader(io.NewSectionReader(file, 0, math.MaxInt64))
+	var offset, lastTime int64
+	var currKey string
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if k, ok := key.find(line); ok {
+				currKey = k
+			}
+			if currKey != "" && values[currKey] == nil {
+				if v, ok := value.find(line); ok {
+					if lineTime := filechunk.GetTimeStampFromLine(string(line)); lineTime > 1 {
+						lastTime = lineTime
+					}
+					values[currKey] = &keyedValue{value: v, offset: offset, time: lastTime}
+				}
+			}
+			offset += int64(len(line))
+		}
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// findDivergence scans all the files at once and returns the first key that
+// more than one file has a value for and where those values are not all the
+// same, or nil if there is none. Keys are in numeric order if they are all
+// numbers, like heights, and in the order of their earliest time otherwise.
+// A file that has no value for a key, because it stopped or had not started
+// yet, does not count as disagreeing.
+func findDivergence(files []io.ReaderAt, key *watch, value *watch) (*divergence, error) {
+	fileValues := make([]keyedValues, len(files))
+	errs := make([]error, len(files))
+	var wg sync.WaitGroup
+	for i := range files {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fileValues[i], errs[i] = scanKeyedValues(files[i], key, value)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	earliest := make(map[string]int64)
+	numeric := true
+	for _, values := range fileValues {
+		for k, v := range values {
+			if t, ok := earliest[k]; !ok || v.time < t {
+				earliest[k] = v.time
+			}
+			if _, err := strconv.ParseFloat(k, 64); err != nil {
+				numeric = false
+			}
+		}
+	}
+	keys := make([]string, 0, len(earliest))
+	for k := range earliest {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if numeric {
+			numA, _ := strconv.ParseFloat(keys[a], 64)
+			numB, _ := strconv.ParseFloat(keys[b], 64)
+			if numA != numB {
+				return numA < numB
+			}
+		} else if earliest[keys[a]] != earliest[keys[b]] {
+			return earliest[keys[a]] < earliest[keys[b]]
+		}
+		return keys[a] < keys[b]
+	})
+
+	compared := 0
+	for _, k := range keys {
+		d := &divergence{key: k, values: make([]*keyedValue, len(files)), compared: compared}
+		var seen []string
+		for i, values := range fileValues {
+			if v := values[k]; v != nil {
+				d.values[i] = v
+				seen = append(seen, v.value)
+			}
+		}
+		if len(seen) < 2 {
+			continue
+		}
+		for _, v := range seen[1:] {
+			if v != seen[0] {
+				for i, v := range d.values {
+					if v != nil {
+						v.line = lineAt(files[i], v.offset)
+					}
+				}
+				return d, nil
+			}
+		}
+		compared++
+	}
+	return nil, nil
+}
+
+// lineAt reads the line that starts at the offset of the file
+func lineAt(file io.ReaderAt, offset int64) []byte {
+	line, _ := bufio.NewReader(io.NewSectionReader(file, offset, math.MaxInt64-offset)).ReadBytes('\n')
+	return line
+}
+
+// describeDivergence returns the report of the divergence: the key, and
+// every file's value and the line it is on, with the values that differ
+// from the one most files have marked
+func describeDivergence(fileNames []string, key *watch, value *watch, d *divergence) string {
+	var report strings.Builder
+	values := make([]string, len(d.values))
+	for i, v := range d.values {
+		if v != nil {
+			values[i] = v.value
+		}
+	}
+	common := mostCommon(values)
+	names := uniqueNames(fileNames)
+	nameWidth := longestName(names)
+
+	fmt.Fprintf(&report, "%s differs at %s %s (the files agreed at %d earlier %s values)\n\n", value.Name, key.Name, d.key, d.compared, key.Name)
+	for i, v := range d.values {
+		if v == nil {
+			fmt.Fprintf(&report, "  %-*s no %s\n", nameWidth, names[i], value.Name)
+			continue
+		}
+		marker := " "
+		if v.value != common {
+			marker = "!"
+		}
+		fmt.Fprintf(&report, "%s %-*s %s\n", marker, nameWidth, names[i], v.value)
+		fmt.Fprintf(&report, "  %-*s %s  %s\n", nameWidth, "", time.Unix(0, v.time).UTC().Format(markTimeFormat), shortLine(v.line))
+	}
+	return report.String()
+}
+
+// ReportDivergence finds the first key where the log files disagree on a
+// value and writes the report of it to out. The key and the value are each
+// a field name or a regular expression whose first group is the value.
+func ReportDivergence(out io.Writer, logFilenames []string, keyExpr string, valueExpr string) error {
+	key, err := newExtractor(keyExpr, "key", nil)
+	if err != nil {
+		return err
+	}
+	value, err := newExtractor(valueExpr, "value", nil)
+	if err != nil {
+		return err
+	}
+
+	files := make([]io.ReaderAt, len(logFilenames))
+	for i, logFilename := range logFilenames {
+		file, err := os.Open(logFilename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		files[i] = file
+	}
+
+	d, err := findDivergence(files, key, value)
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		_, err = fmt.Fprintf(out, "the files agree on %s at every %s\n", value.Name, key.Name)
+		return err
+	}
+	_, err = io.WriteString(out, describeDivergence(logFilenames, key, value, d))
+	return err
+}
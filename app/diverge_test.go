@@ -0,0 +1,39 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+func Example_findDivergence() {
+	logs := []string{
+		"I[2020-05-25|08:45:33.239] Committed state height=1 appHash=00\n" +
+			"I[2020-05-25|08:45:34.239] Committed state height=2 appHash=01\n" +
+			"I[2020-05-25|08:45:35.239] Committed state height=3 appHash=02\n",
+		"I[2020-05-25|08:45:33.241] Committed state height=1 appHash=00\n" +
+			"I[2020-05-25|08:45:34.240] Executing block height=2\n" +
+			"I[2020-05-25|08:45:34.242] Committed state appHash=FF\n",
+		"I[2020-05-25|08:45:33.238] Committed state height=1 appHash=00\n",
+	}
+	files := make([]io.ReaderAt, len(logs))
+	for i := range logs {
+		files[i] = strings.NewReader(logs[i])
+	}
+	key, _ := newExtractor("height", "key", nil)
+	value, _ := newExtractor(`appHash=(\w+)`, "value", nil)
+	d, err := findDivergence(files, key, value)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print(describeDivergence([]string{"logs/node0-json.log", "logs/node0-json.log.1", "logs/node2-json.log"}, key, value, d))
+
+	// Output: value differs at height 2 (the files agreed at 1 earlier height values)
+	//
+	//   node0-json.log   01
+	//                    08:45:34.239  I[2020-05-25|08:45:34.239] Committed state height=2 appHash=01
+	// ! node0-json.log.1 FF
+	//                    08:45:34.242  I[2020-05-25|08:45:34.242] Committed state appHash=FF
+	//   node2-json.log   no value
+}
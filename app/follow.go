@@ -0,0 +1,224 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// maxFollowHits is the most hits of a followed ID that are listed in the
+// summary, so that following a very common ID does not list whole files
+const maxFollowHits = 500
+
+// followMessageWidth is how much of each hit's log line the summary shows
+const followMessageWidth = 160
+
+// followID is the ID being followed. While it is set, stepping only goes
+// through the log lines that contain it, and it is highlighted in every pane.
+var followID string
+
+// followRule highlights the followed ID on top of the highlight rules
+var followRule *highlightRule
+
+// idFieldPattern finds the values of fields whose names say they hold an
+// ID, like "peer=", "txHash=", "trace_id=" or "\"request_id\":"
+var idFieldPattern = regexp.MustCompile(`(?i)\b[A-Za-z_.]*(?:id|hash|peer|trace)"?\s*[=:]\s*"?([0-9A-Za-z_\-]{6,})`)
+
+// hexIDPattern finds hex IDs like hashes and UUIDs
+var hexIDPattern = regexp.MustCompile(`\b[0-9A-Fa-f]{8,}(?:-[0-9A-Fa-f]{4,})*\b`)
+
+// findID returns the first ID in the log line: the value of a field whose
+// name says it holds an ID, or else the first long hex string. It returns
+// an empty string if there is none.
+func findID(line []byte) string {
+	if match := idFieldPattern.FindSubmatch(line); match != nil {
+		return string(match[1])
+	}
+	return string(hexIDPattern.Find(line))
+}
+
+// setFollowID starts following the ID, or stops following if it is empty
+func setFollowID(id string) {
+	followID = id
+	followRule = nil
+	if id != "" {
+		followRule = &highlightRule{
+			Match: regexp.QuoteMeta(id),
+			FG:    currentTheme.CurrentText,
+			BG:    currentTheme.Accent,
+			Bold:  true,
+		}
+		followRule.compile()
+	}
+}
+
+// followHit is a log line that contains the followed ID
+type followHit struct {
+	file   int    // The index of the file
+	offset int64  // The offset of the line
+	time   int64  // The time of the line, or of the last line before it with a time
+	line   []byte // The line
+}
+
+// scanFollowHits reads the whole file and finds the lines that contain the ID
+func scanFollowHits(file io.ReaderAt, index int, id []byte) ([]*followHit, error) {
+	var hits []*followHit
+	reader := bufio.NewReader(io.NewSectionReader(file, 0, math.MaxInt64))
+	var offset, lastTime int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if lineTime := filechunk.GetTimeStampFromLine(string(line)); lineTime > 1 {
+				lastTime = lineTime
+			}
+			if bytes.Contains(line, id) {
+				hits = append(hits, &followHit{file: index, offset: offset, time: lastTime, line: line})
+			}
+		}
+		offset += int64(len(line))
+		if err == io.EOF {
+			return hits, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// findFollowHits scans all the files at once and returns every log line
+// of every file that contains the ID, in time order
+func findFollowHits(files []io.ReaderAt, id string) ([]*followHit, error) {
+	found := make([][]*followHit, len(files))
+	errs := make([]error, len(files))
+	var wg sync.WaitGroup
+	for i := range files {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			found[i], errs[i] = scanFollowHits(files[i], i, []byte(id))
+		}(i)
+	}
+	wg.Wait()
+
+	var hits []*followHit
+	for i := range files {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		hits = append(hits, found[i]...)
+	}
+	sort.SliceStable(hits, func(a, b int) bool {
+		return hits[a].time < hits[b].time
+	})
+	return hits, nil
+}
+
+// parseHitNumber reads the number of a hit as it is listed by the hits command
+func parseHitNumber(arg string, hits []*followHit) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(hits) {
+		return -1, fmt.Errorf("no hit %s, type hits to list them", arg)
+	}
+	return n - 1, nil
+}
+
+// adjacentHit returns the index of the hit after the current position of
+// the files, or before it if forward is false, or -1 if there is none.
+// When the file that moved last is on one of the hits, the one next to it
+// in the list is returned. Otherwise the hits are compared with the time
+// of that file's line, and with the current lines of their own files when
+// they are at the same time.
+func adjacentHit(fileViews []fileView, hits []*followHit, forward bool) int {
+	active := activeFileIndex(fileViews)
+	chunk := fileViews[active].currChunk
+	for i, hit := range hits {
+		if hit.file == active && hit.offset == chunk.FileOffsetStart {
+			if forward && i+1 < len(hits) {
+				return i + 1
+			} else if !forward && i > 0 {
+				return i - 1
+			}
+			return -1
+		}
+	}
+
+	currTime := lineTime(chunk)
+	if forward {
+		for i, hit := range hits {
+			if hit.time > currTime || hit.time == currTime && hit.offset > fileViews[hit.file].currChunk.FileOffsetStart {
+				return i
+			}
+		}
+		return -1
+	}
+	for i := len(hits) - 1; i >= 0; i-- {
+		hit := hits[i]
+		if hit.time < currTime || hit.time == currTime && hit.offset < fileViews[hit.file].currChunk.FileOffsetStart {
+			return i
+		}
+	}
+	return -1
+}
+
+// shortLine returns the log line with its runs of spaces collapsed and
+// shortened to fit on one line of a summary
+func shortLine(line []byte) string {
+	message := []rune(strings.Join(strings.Fields(string(line)), " "))
+	if len(message) > followMessageWidth {
+		return string(message[:followMessageWidth]) + "…"
+	}
+	return string(message)
+}
+
+// describeFollowHits returns the summary of the hits of the ID: how many
+// there are in each file and over what time, followed by each hit's file,
+// time and message, numbered so that they can be jumped to.
+func describeFollowHits(fileNames []string, id string, hits []*followHit) string {
+	var summary strings.Builder
+	if len(hits) == 0 {
+		fmt.Fprintf(&summary, "%s is not in any of the files\n", id)
+		return summary.String()
+	}
+
+	hitsPerFile := make([]int, len(fileNames))
+	for _, hit := range hits {
+		hitsPerFile[hit.file]++
+	}
+	var filesWithHits int
+	for _, count := range hitsPerFile {
+		if count > 0 {
+			filesWithHits++
+		}
+	}
+
+	names := uniqueNames(fileNames)
+	nameWidth := longestName(names)
+	firstTime := hits[0].time
+	lastTime := hits[len(hits)-1].time
+	fmt.Fprintf(&summary, "%s: %d lines in %d of %d files over %s\n", id, len(hits), filesWithHits, len(fileNames), time.Duration(lastTime-firstTime))
+	fmt.Fprintf(&summary, "first at %s, last at %s\n\n", time.Unix(0, firstTime).UTC().Format(clusterTimeFormat), time.Unix(0, lastTime).UTC().Format(clusterTimeFormat))
+	for i := range fileNames {
+		fmt.Fprintf(&summary, "%-*s %d lines\n", nameWidth, names[i], hitsPerFile[i])
+	}
+	fmt.Fprintf(&summary, "\n")
+
+	for i, hit := range hits {
+		if i == maxFollowHits {
+			fmt.Fprintf(&summary, "... and %d more\n", len(hits)-maxFollowHits)
+			break
+		}
+		fmt.Fprintf(&summary, "%4d. %-*s %s  %s\n", i+1, nameWidth, names[hit.file],
+			time.Unix(0, hit.time).UTC().Format(markTimeFormat), shortLine(hit.line))
+	}
+	return summary.String()
+}
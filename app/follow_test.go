@@ -0,0 +1,81 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+func Example_findFollowHits() {
+	logs := []string{
+		"I[2020-05-25|08:45:30.000] Added peer peer=8f698d97563b\n" +
+			"I[2020-05-25|08:45:31.000] Committed state height=1\n" +
+			"  with a line about 8f698d97563b without a time\n",
+		"I[2020-05-25|08:45:30.500] Dialing peer peer=8f698d97563b\n" +
+			"I[2020-05-25|08:45:32.000] Stopping peer peer=8f698d97563b\n",
+	}
+	files := make([]io.ReaderAt, len(logs))
+	for i := range logs {
+		files[i] = strings.NewReader(logs[i])
+	}
+
+	hits, _ := findFollowHits(files, "8f698d97563b")
+	for _, hit := range hits {
+		fmt.Println(hit.file, hit.offset)
+	}
+	fmt.Print(describeFollowHits([]string{"logs/node0-json.log", "logs/node1-json.log"}, "8f698d97563b", hits))
+
+	// Output: 0 0
+	// 1 0
+	// 0 108
+	// 1 58
+	// 8f698d97563b: 4 lines in 2 of 2 files over 2s
+	// first at 2020-05-25 08:45:30.000 UTC, last at 2020-05-25 08:45:32.000 UTC
+	//
+	// node0-json.log 2 lines
+	// node1-json.log 2 lines
+	//
+	//    1. node0-json.log 08:45:30.000  I[2020-05-25|08:45:30.000] Added peer peer=8f698d97563b
+	//    2. node1-json.log 08:45:30.500  I[2020-05-25|08:45:30.500] Dialing peer peer=8f698d97563b
+	//    3. node0-json.log 08:45:31.000  with a line about 8f698d97563b without a time
+	//    4. node1-json.log 08:45:32.000  I[2020-05-25|08:45:32.000] Stopping peer peer=8f698d97563b
+}
+
+func Example_adjacentHit() {
+	fileViews := openLogFileViews(
+		"I[2020-05-25|08:45:30.000] Added peer peer=8f698d97563b\n"+
+			"I[2020-05-25|08:45:31.000] Committed state height=1\n"+
+			"I[2020-05-25|08:45:31.000] Removed peer peer=8f698d97563b\n",
+		"I[2020-05-25|08:45:30.500] Dialing peer peer=8f698d97563b\n"+
+			"I[2020-05-25|08:45:31.000] Stopping peer peer=8f698d97563b\n",
+	)
+	files := []io.ReaderAt{fileViews[0].file, fileViews[1].file}
+	hits, _ := findFollowHits(files, "8f698d97563b")
+
+	step := func(forward bool) {
+		i := adjacentHit(fileViews, hits, forward)
+		if i < 0 {
+			fmt.Println("no more hits")
+			return
+		}
+		hit := hits[i]
+		SyncAllToFileChunk(fileViews, hit.file, fileViews[hit.file].currChunk.GetFileChunkAtOffset(hit.offset))
+		fmt.Println(i+1, hit.file, hit.offset)
+	}
+	SyncAllToFileChunk(fileViews, 0, fileViews[0].currChunk)
+	for i := 0; i < 4; i++ {
+		step(true)
+	}
+	for i := 0; i < 4; i++ {
+		step(false)
+	}
+
+	// Output: 2 1 0
+	// 3 0 108
+	// 4 1 58
+	// no more hits
+	// 3 0 108
+	// 2 1 0
+	// 1 0 0
+	// no more hits
+}
@@ -0,0 +1,280 @@
+package app
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// defaultGapDuration is the shortest silence that is listed as a gap when
+// no duration is given
+const defaultGapDuration = 2 * time.Second
+
+// maxGapsListed is the most gaps that are kept and listed, so that a
+// duration that is too short for the files does not keep and list every line
+const maxGapsListed = 500
+
+// gap is a time when a file has no log lines, or none that match the
+// pattern it was searched with, for longer than the duration it was
+// searched with
+type gap struct {
+	file       int   // The index of the file
+	start      int64 // The time of the last line before the gap, or of the earliest line of any file
+	end        int64 // The time of the first line after the gap, or of the latest line of any file
+	offset     int64 // The offset of the last line before the gap, or of the first line after it if it starts the file, or -1 if the file has no lines
+	startsFile bool  // Whether the gap is before the first line of the file
+}
+
+// duration returns how long the gap lasts
+func (g *gap) duration() time.Duration {
+	return time.Duration(g.end - g.start)
+}
+
+// gapHeap holds the longest gaps found so far, with the shortest of them
+// on top so that it can be replaced by a longer one
+type gapHeap []*gap
+
+func (h gapHeap) Len() int            { return len(h) }
+func (h gapHeap) Less(a, b int) bool  { return h[a].duration() < h[b].duration() }
+func (h gapHeap) Swap(a, b int)       { h[a], h[b] = h[b], h[a] }
+func (h *gapHeap) Push(x interface{}) { *h = append(*h, x.(*gap)) }
+func (h *gapHeap) Pop() interface{} {
+	old := *h
+	g := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return g
+}
+
+// add keeps the gap if it is one of the maxGapsListed longest
+func (h *gapHeap) add(g *gap) {
+	if h.Len() < maxGapsListed {
+		heap.Push(h, g)
+	} else if g.duration() > (*h)[0].duration() {
+		(*h)[0] = g
+		heap.Fix(h, 0)
+	}
+}
+
+// fileGaps is what the scan of one file found: its longest gaps between
+// lines and its first and last lines, which make gaps at its start and end
+// if other files log before or after it
+type fileGaps struct {
+	gaps  gapHeap
+	total int  // How many gaps there are between the lines, kept or not
+	first *gap // The first line of the file, as the end of a gap
+	last  *gap // The last line of the file, as the start of a gap
+}
+
+// scanGaps reads the whole file and finds the gaps between its lines that
+// are longer than minDuration, keeping the maxGapsListed longest of them.
+// Only the lines that match the pattern are
+// counted if it is not nil, so that a missing heartbeat can be found in a
+// file that logs other things in between. Lines without a time are skipped.
+func scanGaps(file io.ReaderAt, index int, minDuration time.Duration, pattern *regexp.Regexp) (*fileGaps, error) {
+	found := &fileGaps{}
+	reader := bufio.NewReader(io.NewSectionReader(file, 0, math.MaxInt64))
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && (pattern == nil || pattern.Match(line)) {
+			if lineTime := filechunk.GetTimeStampFromLine(string(line)); lineTime > 1 {
+				if found.first == nil {
+					found.first = &gap{file: index, end: lineTime, offset: offset, startsFile: true}
+				} else if lineTime-found.last.start > int64(minDuration) {
+					found.gaps.add(&gap{file: index, start: found.last.start, end: lineTime, offset: found.last.offset})
+					found.total++
+				}
+				found.last = &gap{file: index, start: lineTime, offset: offset}
+			}
+		}
+		offset += int64(len(line))
+		if err == io.EOF {
+			return found, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// findGaps scans all the files at once and returns the maxGapsListed
+// longest of their gaps longer than minDuration, longest first, and how
+// many gaps there are in all. Besides the gaps between the lines of a file,
+// a file that starts logging after the earliest line of all the files, or
+// stops before the latest one, has a gap at its start or end, and a file
+// without any line that counts is one gap over the whole time.
+func findGaps(files []io.ReaderAt, minDuration time.Duration, pattern *regexp.Regexp) ([]*gap, int, error) {
+	found := make([]*fileGaps, len(files))
+	errs := make([]error, len(files))
+	var wg sync.WaitGroup
+	for i := range files {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			found[i], errs[i] = scanGaps(files[i], i, minDuration, pattern)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var start, end int64
+	for _, f := range found {
+		if f.first == nil {
+			continue
+		}
+		if start == 0 || f.first.end < start {
+			start = f.first.end
+		}
+		if f.last.start > end {
+			end = f.last.start
+		}
+	}
+
+	var gaps []*gap
+	var total int
+	for i, f := range found {
+		if f.first == nil {
+			if end-start > int64(minDuration) {
+				gaps = append(gaps, &gap{file: i, start: start, end: end, offset: -1})
+			}
+			continue
+		}
+		if f.first.end-start > int64(minDuration) {
+			f.first.start = start
+			gaps = append(gaps, f.first)
+		}
+		gaps = append(gaps, f.gaps...)
+		total += f.total - len(f.gaps)
+		if end-f.last.start > int64(minDuration) {
+			f.last.end = end
+			gaps = append(gaps, f.last)
+		}
+	}
+	total += len(gaps)
+	sort.Slice(gaps, func(a, b int) bool {
+		if gaps[a].duration() != gaps[b].duration() {
+			return gaps[a].duration() > gaps[b].duration()
+		}
+		if gaps[a].start != gaps[b].start {
+			return gaps[a].start < gaps[b].start
+		}
+		return gaps[a].file < gaps[b].file
+	})
+	if len(gaps) > maxGapsListed {
+		gaps = gaps[:maxGapsListed]
+	}
+	return gaps, total, nil
+}
+
+// parseGapArgs reads the arguments of the gaps command, which are an
+// optional duration followed by an optional regular expression
+func parseGapArgs(args []string) (time.Duration, *regexp.Regexp, error) {
+	minDuration := defaultGapDuration
+	if len(args) > 0 {
+		if d, err := time.ParseDuration(args[0]); err == nil {
+			if d <= 0 {
+				return 0, nil, fmt.Errorf("the gap duration has to be more than 0")
+			}
+			minDuration = d
+			args = args[1:]
+		}
+	}
+	if len(args) == 0 {
+		return minDuration, nil, nil
+	}
+	pattern, err := regexp.Compile(strings.Join(args, " "))
+	if err != nil {
+		return 0, nil, fmt.Errorf("bad regular expression: %v", err)
+	}
+	return minDuration, pattern, nil
+}
+
+// parseGapNumber reads the number of a gap as it is listed by the gaps command
+func parseGapNumber(arg string, gaps []*gap) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(gaps) {
+		return -1, fmt.Errorf("no gap %s, type gaps to list them", arg)
+	}
+	return n - 1, nil
+}
+
+// describeGaps lists the gaps longest first, numbered so that they can be
+// jumped to, with the file, the times, and the line before each gap, which
+// is read from the file. total is how many gaps were found, of which only
+// the longest are kept.
+func describeGaps(fileNames []string, files []io.ReaderAt, minDuration time.Duration, pattern *regexp.Regexp, gaps []*gap, total int) string {
+	var report strings.Builder
+	what := "lines"
+	if pattern != nil {
+		what = "lines matching " + pattern.String()
+	}
+	if len(gaps) == 0 {
+		fmt.Fprintf(&report, "no file goes more than %s without %s\n", minDuration, what)
+		return report.String()
+	}
+
+	fmt.Fprintf(&report, "%s when a file went more than %s without %s\n\n", countOf(total, "gap"), minDuration, what)
+	names := uniqueNames(fileNames)
+	nameWidth := longestName(names)
+	for i, g := range gaps {
+		fmt.Fprintf(&report, "%4d. %-*s %s - %s  %s\n", i+1, nameWidth, names[g.file],
+			time.Unix(0, g.start).UTC().Format(markTimeFormat), time.Unix(0, g.end).UTC().Format(markTimeFormat), g.duration())
+		switch {
+		case g.offset < 0:
+			fmt.Fprintf(&report, "      %-*s no %s at all\n", nameWidth, "", what)
+		case g.startsFile:
+			fmt.Fprintf(&report, "      %-*s first: %s\n", nameWidth, "", shortLine(lineAt(files[g.file], g.offset)))
+		default:
+			fmt.Fprintf(&report, "      %-*s last:  %s\n", nameWidth, "", shortLine(lineAt(files[g.file], g.offset)))
+		}
+	}
+	if total > len(gaps) {
+		fmt.Fprintf(&report, "... and %d more\n", total-len(gaps))
+	}
+	return report.String()
+}
+
+// ReportGaps finds the times when a log file has no lines, or none that
+// match the pattern if it is not empty, for longer than minDuration, and
+// writes the list of them, longest first, to out
+func ReportGaps(out io.Writer, logFilenames []string, minDuration time.Duration, patternText string) error {
+	var pattern *regexp.Regexp
+	if patternText != "" {
+		var err error
+		if pattern, err = regexp.Compile(patternText); err != nil {
+			return fmt.Errorf("bad regular expression: %v", err)
+		}
+	}
+
+	files := make([]io.ReaderAt, len(logFilenames))
+	for i, logFilename := range logFilenames {
+		file, err := os.Open(logFilename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		files[i] = file
+	}
+
+	gaps, total, err := findGaps(files, minDuration, pattern)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, describeGaps(logFilenames, files, minDuration, pattern, gaps, total))
+	return err
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+func Example_findGaps() {
+	logs := []string{
+		"I[2020-05-25|08:45:30.000] heartbeat\n" +
+			"I[2020-05-25|08:45:31.000] heartbeat\n" +
+			"I[2020-05-25|08:45:31.500] something else\n" +
+			"I[2020-05-25|08:45:35.000] heartbeat\n" +
+			"I[2020-05-25|08:45:40.000] heartbeat\n",
+		"I[2020-05-25|08:45:32.500] heartbeat\n" +
+			"I[2020-05-25|08:45:33.000] heartbeat\n",
+	}
+	files := make([]io.ReaderAt, len(logs))
+	for i := range logs {
+		files[i] = strings.NewReader(logs[i])
+	}
+	fileNames := []string{"logs/node0-json.log", "logs/node1-json.log"}
+
+	gaps, total, _ := findGaps(files, 2*time.Second, nil)
+	fmt.Print(describeGaps(fileNames, files, 2*time.Second, nil, gaps, total))
+	minDuration, pattern, _ := parseGapArgs([]string{"3s", "heartbeat"})
+	gaps, total, _ = findGaps(files, minDuration, pattern)
+	fmt.Print(describeGaps(fileNames, files, minDuration, pattern, gaps, total))
+
+	// Output: 4 gaps when a file went more than 2s without lines
+	//
+	//    1. node1-json.log 08:45:33.000 - 08:45:40.000  7s
+	//                      last:  I[2020-05-25|08:45:33.000] heartbeat
+	//    2. node0-json.log 08:45:35.000 - 08:45:40.000  5s
+	//                      last:  I[2020-05-25|08:45:35.000] heartbeat
+	//    3. node0-json.log 08:45:31.500 - 08:45:35.000  3.5s
+	//                      last:  I[2020-05-25|08:45:31.500] something else
+	//    4. node1-json.log 08:45:30.000 - 08:45:32.500  2.5s
+	//                      first: I[2020-05-25|08:45:32.500] heartbeat
+	// 3 gaps when a file went more than 3s without lines matching heartbeat
+	//
+	//    1. node1-json.log 08:45:33.000 - 08:45:40.000  7s
+	//                      last:  I[2020-05-25|08:45:33.000] heartbeat
+	//    2. node0-json.log 08:45:35.000 - 08:45:40.000  5s
+	//                      last:  I[2020-05-25|08:45:35.000] heartbeat
+	//    3. node0-json.log 08:45:31.000 - 08:45:35.000  4s
+	//                      last:  I[2020-05-25|08:45:31.000] heartbeat
+}
+
+func Example_findGaps_longest() {
+	var log strings.Builder
+	lineTime := time.Date(2020, 5, 25, 8, 0, 0, 0, time.UTC)
+	for i := 0; i <= 600; i++ {
+		lineTime = lineTime.Add(3*time.Second + time.Duration(i)*time.Millisecond)
+		fmt.Fprintf(&log, "I[%s] heartbeat %d\n", lineTime.Format("2006-01-02|15:04:05.000"), i)
+	}
+	files := []io.ReaderAt{strings.NewReader(log.String())}
+
+	gaps, total, _ := findGaps(files, 2*time.Second, nil)
+	fmt.Println(len(gaps), total, gaps[0].duration(), gaps[len(gaps)-1].duration())
+	report := strings.Split(describeGaps([]string{"node0.log"}, files, 2*time.Second, nil, gaps, total), "\n")
+	fmt.Println(report[0])
+	fmt.Println(report[3])
+	fmt.Println(report[len(report)-2])
+
+	// Output: 500 600 3.6s 3.101s
+	// 600 gaps when a file went more than 2s without lines
+	//                 last:  I[2020-05-25|08:32:59.700] heartbeat 599
+	// ... and 100 more
+}
@@ -0,0 +1,189 @@
+package app
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// gridTimeFormat is how the time of a bucket is shown in the gutter of the grid
+const gridTimeFormat = "15:04:05.000"
+
+// gridGutterWidth is the width of the gutter on the left of the grid
+// where the time of each bucket is shown
+const gridGutterWidth = 13
+
+// bucketSize is how much time each row of the grid view covers.
+// Log lines from different files in the same bucket are shown on the same row.
+var bucketSize = 100 * time.Millisecond
+
+// gridRow is one row of the grid view. A row either has a cell for every
+// file, which is nil if that file has no more log lines in the bucket,
+// or it marks a gap of empty buckets between two rows.
+type gridRow struct {
+	bucketTime    int64                  // The time at the start of the bucket of this row
+	firstInBucket bool                   // Whether this is the first row of its bucket
+	gap           time.Duration          // If this row marks a gap, how long the gap is
+	cells         []*filechunk.FileChunk // The log line of every file on this row
+}
+
+// buildGridRows lays out the merged log lines into rows such that log lines
+// in the same time bucket share rows. If one file has more log lines than the
+// others in a bucket, the bucket takes up more rows and the other files are
+// padded with empty cells. Log lines without a timestamp stay in the bucket
+// of the log line before them in the same file.
+func buildGridRows(lines []mergedLine, numFiles int, bucketSize time.Duration) []gridRow {
+	var rows []gridRow
+	var bucketRows []gridRow
+	var currBucket int64 = -1
+	lineBuckets := make([]int64, numFiles)
+	lineCounts := make([]int, numFiles)
+
+	for _, line := range lines {
+		bucket := lineBuckets[line.index]
+		if line.chunk.LineTimeStamp > 1 {
+			bucket = line.chunk.LineTimeStamp / int64(bucketSize)
+		}
+		if bucket < currBucket {
+			bucket = currBucket
+		}
+		lineBuckets[line.index] = bucket
+
+		if bucket != currBucket {
+			rows = append(rows, bucketRows...)
+			bucketRows = nil
+			for i := range lineCounts {
+				lineCounts[i] = 0
+			}
+			if currBucket >= 0 && bucket > currBucket+1 {
+				rows = append(rows, gridRow{gap: time.Duration(bucket-currBucket-1) * bucketSize})
+			}
+			currBucket = bucket
+		}
+
+		rowIndex := lineCounts[line.index]
+		lineCounts[line.index]++
+		if rowIndex == len(bucketRows) {
+			bucketRows = append(bucketRows, gridRow{
+				bucketTime:    bucket * int64(bucketSize),
+				firstInBucket: rowIndex == 0,
+				cells:         make([]*filechunk.FileChunk, numFiles),
+			})
+		}
+		bucketRows[rowIndex].cells[line.index] = line.chunk
+	}
+
+	return append(rows, bucketRows...)
+}
+
+// gridView is the side by side view of all the files. Each file is a
+// column, and the rows are aligned by time so that log lines from the same
+// time bucket are on the same row. It shows the merged log lines around the
+// current position of the fileViews, with the current line of every file
+// highlighted, so stepping works just like with the stacked file views.
+type gridView struct {
+	*tview.Box
+	fileViews []fileView // All the fileViews, whose current chunks are the position of the grid
+}
+
+// newGridView creates the grid view for all the fileViews
+func newGridView(fileViews []fileView) *gridView {
+	gridView := &gridView{
+		Box:       tview.NewBox(),
+		fileViews: fileViews,
+	}
+	gridView.SetBorder(true).SetTitle("grid")
+	return gridView
+}
+
+// Draw lays out the merged log lines around the current position into rows
+// and draws enough of them to fill the view, with the current lines placed
+// like in the file views according to contextAbovePercent.
+func (gv *gridView) Draw(screen tcell.Screen) {
+	gv.Box.Draw(screen)
+	x, y, width, height := gv.GetInnerRect()
+	numFiles := len(gv.fileViews)
+	if numFiles == 0 || height < 1 {
+		return
+	}
+	gv.SetTitle("grid | bucket " + bucketSize.String())
+
+	columnWidth := (width - gridGutterWidth) / numFiles
+	if columnWidth < 2 {
+		return
+	}
+
+	lines, currStart := mergeAround(gv.fileViews, height*numFiles, height*numFiles)
+	rows := buildGridRows(lines, numFiles, bucketSize)
+
+	// Find the row that has the first current line to place it on the screen
+	currChunk := lines[currStart].chunk
+	currRow := 0
+	for r := range rows {
+		for _, cell := range rows[r].cells {
+			if cell == currChunk {
+				currRow = r
+			}
+		}
+	}
+	firstRow := currRow - (height-1)*contextAbovePercent/100
+	if firstRow < 0 {
+		firstRow = 0
+	}
+
+	for screenRow := 0; screenRow < height && firstRow+screenRow < len(rows); screenRow++ {
+		row := rows[firstRow+screenRow]
+		rowY := y + screenRow
+
+		if row.cells == nil {
+			tview.Print(screen, "··· "+row.gap.String()+" with no log lines ···", x, rowY, width, tview.AlignCenter, themeColor(currentTheme.Muted))
+			continue
+		}
+
+		if row.firstInBucket {
+			bucketTime := time.Unix(0, row.bucketTime).UTC().Format(gridTimeFormat)
+			tview.Print(screen, bucketTime, x, rowY, gridGutterWidth, tview.AlignLeft, themeColor(currentTheme.Accent))
+		}
+
+		for i, cell := range row.cells {
+			cellX := x + gridGutterWidth + i*columnWidth
+			screen.SetContent(cellX, rowY, tview.Borders.Vertical, nil, currentTheme.style(currentTheme.Muted))
+			if cell == nil {
+				continue
+			}
+			text := renderLogLine(bytes.TrimRight(cell.FileChunkBytes, "\r\n"))
+			if cell == gv.fileViews[i].currChunk {
+				text = "[" + currentTheme.CurrentText + ":" + currentTheme.Current + "]" + text
+			}
+			tview.Print(screen, text, cellX+1, rowY, columnWidth-1, tview.AlignLeft, themeColor(currentTheme.Text))
+		}
+	}
+}
+
+// InputHandler steps forward with Tab or the down key and backward with
+// Backtab or the up key, just like in the file views.
+func (gv *gridView) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return gv.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		switch event.Key() {
+		case tcell.KeyTab, tcell.KeyDown:
+			AdvanceNextFileViewForward(gv.fileViews)
+		case tcell.KeyBacktab, tcell.KeyUp:
+			AdvancePrevFileViewBackward(gv.fileViews)
+		}
+	})
+}
+
+// MouseHandler gives the grid view the focus when it is clicked
+func (gv *gridView) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return gv.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		if action == tview.MouseLeftClick && gv.InRect(event.Position()) {
+			setFocus(gv)
+			return true, nil
+		}
+		return false, nil
+	})
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+func Example_buildGridRows() {
+	base := time.Date(2020, 5, 25, 8, 45, 30, 0, time.UTC).UnixNano()
+	line := func(index int, ms int64, text string) mergedLine {
+		chunk := &filechunk.FileChunk{FileChunkBytes: []byte(text)}
+		if ms >= 0 {
+			chunk.LineTimeStamp = base + ms*int64(time.Millisecond)
+		}
+		return mergedLine{index: index, chunk: chunk}
+	}
+	lines := []mergedLine{
+		line(0, 10, "a1"),
+		line(1, 20, "b1"),
+		line(0, 50, "a2"),
+		line(0, -1, "a2 continued"),
+		line(1, 120, "b2"),
+		line(0, 90, "a3 logged late"),
+		line(1, 450, "b3"),
+	}
+
+	for _, row := range buildGridRows(lines, 2, 100*time.Millisecond) {
+		if row.gap > 0 {
+			fmt.Println("gap of", row.gap)
+			continue
+		}
+		var cells []string
+		for _, cell := range row.cells {
+			text := "-"
+			if cell != nil {
+				text = string(cell.FileChunkBytes)
+			}
+			cells = append(cells, text)
+		}
+		fmt.Println(time.Unix(0, row.bucketTime).UTC().Format(gridTimeFormat), row.firstInBucket, strings.Join(cells, " | "))
+	}
+
+	// Output: 08:45:30.000 true a1 | b1
+	// 08:45:30.000 false a2 | -
+	// 08:45:30.000 false a2 continued | -
+	// 08:45:30.100 true a3 logged late | b2
+	// gap of 200ms
+	// 08:45:30.400 true - | b3
+}
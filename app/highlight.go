@@ -0,0 +1,292 @@
+package app
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rivo/tview"
+)
+
+// autoColor is the color of a highlight rule that picks a color from the
+// text it matches, so that the same peer ID or block height always gets the
+// same color
+const autoColor = "auto"
+
+// highlightRule colors the parts of log lines that match it. A rule matches
+// either a regular expression anywhere in the line, or the value of a field
+// written as key=value or as "key":"value" in JSON lines. A config file has
+// rules like:
+//
+//	{"field": "module", "value": "consensus", "line": true, "fg": "aqua"}
+//	{"field": "height", "fg": "auto", "bold": true}
+//	{"match": "peer=[0-9a-f]{40}", "fg": "auto"}
+//	{"match": "(?i)panic", "bg": "red", "gutter": "!"}
+type highlightRule struct {
+	Match  string `json:"match,omitempty"`  // A regular expression to look for in the line
+	Field  string `json:"field,omitempty"`  // The name of a field whose value is colored
+	Value  string `json:"value,omitempty"`  // A regular expression the whole value of the field has to match, any value if empty
+	Line   bool   `json:"line,omitempty"`   // Whether to color the whole line instead of only the matching text
+	FG     string `json:"fg,omitempty"`     // The text color, or "auto"
+	BG     string `json:"bg,omitempty"`     // The background color, or "auto"
+	Bold   bool   `json:"bold,omitempty"`   // Whether to make the text bold
+	Gutter string `json:"gutter,omitempty"` // A character shown in front of the lines that match
+
+	pattern      *regexp.Regexp // The compiled Match, or the pattern that finds the field
+	valuePattern *regexp.Regexp // The compiled Value
+}
+
+// fieldNamePattern matches the names of key=value fields
+var fieldNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// fieldValuePattern returns the pattern that finds the field written as
+// key=value or as "key":"value" in JSON lines, with the value as its
+// first group
+func fieldValuePattern(field string) *regexp.Regexp {
+	return regexp.MustCompile(`\b` + regexp.QuoteMeta(field) + `"?\s*[=:]\s*"?([^\s",}\]\\]+)`)
+}
+
+// highlightRules are the rules applied to every log line that is shown,
+// in order, so later rules are drawn over earlier ones where they overlap
+var highlightRules []*highlightRule
+
+// highlightSpan is the part of a line that a rule matched
+type highlightSpan struct {
+	start, end int       // The byte offsets of the matching text in the line
+	style      textStyle // The colors and attributes the rule gives the text
+	gutter     string    // The gutter marker of the rule, if it has one
+}
+
+// compile checks the rule and compiles its regular expressions
+func (r *highlightRule) compile() error {
+	var err error
+	switch {
+	case r.Match != "" && r.Field != "":
+		return fmt.Errorf("a highlight rule has either a match or a field, not both")
+	case r.Match != "":
+		if r.pattern, err = regexp.Compile(r.Match); err != nil {
+			return fmt.Errorf("bad highlight match %q: %v", r.Match, err)
+		}
+	case r.Field != "":
+		r.pattern = fieldValuePattern(r.Field)
+		if r.Value != "" {
+			if r.valuePattern, err = regexp.Compile(`^(?:` + r.Value + `)$`); err != nil {
+				return fmt.Errorf("bad highlight value %q: %v", r.Value, err)
+			}
+		}
+	default:
+		return fmt.Errorf("a highlight rule needs a match or a field")
+	}
+
+	for _, c := range []string{r.FG, r.BG} {
+		if c != "" && c != autoColor && !isColor(c) {
+			return fmt.Errorf("unknown color %q in highlight rule %s", c, r.describe())
+		}
+	}
+	if utf8.RuneCountInString(r.Gutter) > 1 {
+		return fmt.Errorf("the gutter of highlight rule %s has to be a single character", r.describe())
+	}
+	return nil
+}
+
+// describe returns the rule as it is shown in the list of rules
+func (r *highlightRule) describe() string {
+	var parts []string
+	if r.Match != "" {
+		parts = append(parts, strconv.Quote(r.Match))
+	} else if r.Value != "" {
+		parts = append(parts, r.Field+"="+strconv.Quote(r.Value))
+	} else {
+		parts = append(parts, r.Field+"=*")
+	}
+	if r.Line {
+		parts = append(parts, "line")
+	}
+	if r.FG != "" {
+		parts = append(parts, "fg="+r.FG)
+	}
+	if r.BG != "" {
+		parts = append(parts, "bg="+r.BG)
+	}
+	if r.Bold {
+		parts = append(parts, "bold")
+	}
+	if r.Gutter != "" {
+		parts = append(parts, "gutter="+r.Gutter)
+	}
+	return strings.Join(parts, " ")
+}
+
+// pickColor returns the color of the rule for the matching text, picking
+// one of the auto colors of the theme from a hash of the text for "auto"
+func pickColor(ruleColor string, text string) string {
+	if ruleColor != autoColor || len(currentTheme.Auto) == 0 {
+		return ruleColor
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(text))
+	return currentTheme.Auto[hash.Sum32()%uint32(len(currentTheme.Auto))]
+}
+
+// spans returns the parts of the line that the rule matches
+func (r *highlightRule) spans(line string) []highlightSpan {
+	var spans []highlightSpan
+	for _, match := range r.pattern.FindAllStringSubmatchIndex(line, -1) {
+		start, end := match[0], match[1]
+		if r.Field != "" {
+			start, end = match[2], match[3]
+			if r.valuePattern != nil && !r.valuePattern.MatchString(line[start:end]) {
+				continue
+			}
+		}
+		if start == end {
+			continue
+		}
+
+		text := line[start:end]
+		span := highlightSpan{
+			start:  start,
+			end:    end,
+			gutter: r.Gutter,
+			style: textStyle{
+				fg: pickColor(r.FG, text),
+				bg: pickColor(r.BG, text),
+			},
+		}
+		if r.Bold {
+			span.style.attrs = "b"
+		}
+		if r.Line {
+			span.start, span.end = 0, len(line)
+			return append(spans, span)
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// highlightLine applies the highlight rules to the line. It returns the
+// style each byte of the line gets from the rules, which is nil if no rule
+// matched, and the gutter marker for the line.
+func highlightLine(line string) ([]*textStyle, string) {
+	var overlay []*textStyle
+	gutter := ""
+	rules := highlightRules
+	if followRule != nil {
+		rules = append(rules[:len(rules):len(rules)], followRule)
+	}
+	for _, rule := range rules {
+		for _, span := range rule.spans(line) {
+			if overlay == nil {
+				overlay = make([]*textStyle, len(line))
+			}
+			for i := span.start; i < span.end; i++ {
+				style := span.style
+				if overlay[i] != nil {
+					style = overlay[i].over(&span.style)
+				}
+				overlay[i] = &style
+			}
+			if span.gutter != "" {
+				gutter = "[" + orDefault(span.style.fg, currentTheme.Accent) + "]" + tview.Escape(span.gutter) + "[-]"
+			}
+		}
+	}
+	return overlay, gutter
+}
+
+// hasGutter tells whether any highlight rule has a gutter marker, in which
+// case every line is shown after a gutter so that the lines stay aligned
+func hasGutter() bool {
+	for _, rule := range highlightRules {
+		if rule.Gutter != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// orDefault returns the value, or the default if the value is empty
+func orDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// over returns the style with the highlight style drawn over it
+func (ts textStyle) over(highlight *textStyle) textStyle {
+	if highlight == nil {
+		return ts
+	}
+	if highlight.fg != "" {
+		ts.fg = highlight.fg
+	}
+	if highlight.bg != "" {
+		ts.bg = highlight.bg
+	}
+	for _, flag := range highlight.attrs {
+		ts.attrs = withAttr(ts.attrs, string(flag), true)
+	}
+	return ts
+}
+
+// parseHighlightArgs makes a rule from the arguments of the highlight
+// command, which are a regular expression or a field as FIELD=VALUE,
+// followed by options like "fg=auto", "bg=blue", "bold", "line" and
+// "gutter=*". Without any colors the matching text gets an auto color.
+func parseHighlightArgs(args []string) (*highlightRule, error) {
+	rule := &highlightRule{}
+	if field := strings.SplitN(args[0], "=", 2); len(field) == 2 && fieldNamePattern.MatchString(field[0]) {
+		rule.Field = field[0]
+		if field[1] != "*" {
+			rule.Value = field[1]
+		}
+	} else {
+		rule.Match = args[0]
+	}
+
+	for _, option := range args[1:] {
+		name, value := option, ""
+		if i := strings.Index(option, "="); i >= 0 {
+			name, value = option[:i], option[i+1:]
+		}
+		switch name {
+		case "fg":
+			rule.FG = value
+		case "bg":
+			rule.BG = value
+		case "bold":
+			rule.Bold = true
+		case "line":
+			rule.Line = true
+		case "gutter":
+			rule.Gutter = value
+		default:
+			return nil, fmt.Errorf("unknown highlight option %q, expected fg=COLOR, bg=COLOR, bold, line or gutter=CHAR", option)
+		}
+	}
+	if rule.FG == "" && rule.BG == "" && !rule.Bold && rule.Gutter == "" {
+		rule.FG = autoColor
+	}
+	if err := rule.compile(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// describeHighlightRules lists the highlight rules, numbered so that they
+// can be removed
+func describeHighlightRules() string {
+	if len(highlightRules) == 0 {
+		return "no highlight rules"
+	}
+	var parts []string
+	for i, rule := range highlightRules {
+		parts = append(parts, fmt.Sprintf("%d. %s", i+1, rule.describe()))
+	}
+	return strings.Join(parts, "  ")
+}
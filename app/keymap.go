@@ -0,0 +1,296 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell"
+)
+
+// keyAction is something the user can do by pressing a key.
+// The names are used in the keymap presets and in the config file.
+type keyAction struct {
+	name        string // The name of the action used in keymaps
+	description string // What the action does, shown in the help
+}
+
+// keyActions are all the actions that keys can be bound to,
+// in the order they are listed in the help.
+var keyActions = []keyAction{
+	{"step-forward", "step forward one log line in time order"},
+	{"step-backward", "step backward one log line in time order"},
+	{"select-next-line", "select the next line in the focused pane and sync the others"},
+	{"select-prev-line", "select the previous line in the focused pane and sync the others"},
+	{"time-forward", "step all files forward by the time quantum"},
+	{"time-backward", "step all files backward by the time quantum"},
+	{"head", "jump all files to the beginning"},
+	{"tail", "jump all files to the end"},
+	{"nav-back", "go back to where all the files were before the last jump"},
+	{"nav-forward", "go forward again to where nav-back came from"},
+	{"add-mark", "mark the current position with the next free name like m1"},
+	{"next-mark", "jump to the next mark in time"},
+	{"prev-mark", "jump to the previous mark in time"},
+	{"next-restart", "jump to the next restart of any file in time"},
+	{"prev-restart", "jump to the previous restart of any file in time"},
+	{"annotate", "type a note in the command box for the current line of the focused pane"},
+	{"search", "type a search in the command box"},
+	{"search-next", "find the next line matching the last search"},
+	{"search-prev", "find the previous line matching the last search"},
+	{"follow", "follow the ID in the current line, stepping only through the lines with it, or stop following"},
+	{"focus-next-pane", "focus the next pane"},
+	{"focus-prev-pane", "focus the previous pane"},
+	{"focus-command", "focus the command box"},
+	{"zoom-pane", "show the focused pane full screen, or show all panes again"},
+	{"next-layout", "switch between the rows, columns and grid layouts"},
+	{"next-view", "switch between the panes, grid and merged views"},
+	{"help", "show or hide this help"},
+	{"quit", "quit logsync"},
+}
+
+// noAction is used in the config file to remove a binding from the preset
+const noAction = "none"
+
+// keymapPresets are the keymaps that can be chosen in the config file.
+// Keys are named like tcell names them, such as "Tab", "Ctrl-N", "PgDn" or
+// "Alt-x", and a single character for printable keys. A sequence of keys
+// is written with spaces in between, like "g g".
+var keymapPresets = map[string]map[string]string{
+	"default": {
+		"Tab":       "step-forward",
+		"Backtab":   "step-backward",
+		"Down":      "select-next-line",
+		"Up":        "select-prev-line",
+		"]":         "time-forward",
+		"[":         "time-backward",
+		"Home":      "head",
+		"End":       "tail",
+		"Alt-Left":  "nav-back",
+		"Alt-Right": "nav-forward",
+		"m":         "add-mark",
+		"'":         "next-mark",
+		"`":         "prev-mark",
+		"}":         "next-restart",
+		"{":         "prev-restart",
+		"/":         "search",
+		"n":         "search-next",
+		"N":         "search-prev",
+		"f":         "follow",
+		"Ctrl-N":    "focus-next-pane",
+		"Ctrl-P":    "focus-prev-pane",
+		":":         "focus-command",
+		"z":         "zoom-pane",
+		"L":         "next-layout",
+		"V":         "next-view",
+		"?":         "help",
+		"F1":        "help",
+	},
+	"vim": {
+		"j":         "step-forward",
+		"k":         "step-backward",
+		"Tab":       "step-forward",
+		"Backtab":   "step-backward",
+		"Down":      "select-next-line",
+		"Up":        "select-prev-line",
+		"]":         "time-forward",
+		"[":         "time-backward",
+		"g g":       "head",
+		"G":         "tail",
+		"Ctrl-O":    "nav-back",
+		"Alt-Left":  "nav-back",
+		"Alt-Right": "nav-forward",
+		"m":         "add-mark",
+		"'":         "next-mark",
+		"`":         "prev-mark",
+		"}":         "next-restart",
+		"{":         "prev-restart",
+		"/":         "search",
+		"n":         "search-next",
+		"N":         "search-prev",
+		"*":         "follow",
+		"Ctrl-W w":  "focus-next-pane",
+		"Ctrl-W W":  "focus-prev-pane",
+		":":         "focus-command",
+		"Ctrl-W o":  "zoom-pane",
+		"Ctrl-W L":  "next-layout",
+		"Ctrl-W V":  "next-view",
+		"?":         "help",
+		"F1":        "help",
+		"Z Z":       "quit",
+	},
+	"emacs": {
+		"Ctrl-N":        "step-forward",
+		"Ctrl-P":        "step-backward",
+		"Tab":           "step-forward",
+		"Backtab":       "step-backward",
+		"Down":          "select-next-line",
+		"Up":            "select-prev-line",
+		"Alt-]":         "time-forward",
+		"Alt-[":         "time-backward",
+		"Alt-<":         "head",
+		"Alt->":         "tail",
+		"Alt-,":         "nav-back",
+		"Alt-.":         "nav-forward",
+		"Alt-Left":      "nav-back",
+		"Alt-Right":     "nav-forward",
+		"Ctrl-X a":      "annotate",
+		"Ctrl-X r m":    "add-mark",
+		"Ctrl-X r n":    "next-mark",
+		"Ctrl-X r p":    "prev-mark",
+		"Alt-}":         "next-restart",
+		"Alt-{":         "prev-restart",
+		"Ctrl-S":        "search",
+		"Alt-s":         "search-next",
+		"Alt-r":         "search-prev",
+		"Ctrl-X f":      "follow",
+		"Ctrl-X o":      "focus-next-pane",
+		"Ctrl-X p":      "focus-prev-pane",
+		"Alt-x":         "focus-command",
+		"Ctrl-X 1":      "zoom-pane",
+		"Ctrl-X l":      "next-layout",
+		"Ctrl-X v":      "next-view",
+		"F1":            "help",
+		"Ctrl-X Ctrl-C": "quit",
+	},
+}
+
+// keymap maps key sequences to the names of actions
+type keymap struct {
+	preset   string            // The name of the preset the keymap started from
+	bindings map[string]string // The action for every key sequence
+	pending  []string          // The keys of a sequence that have been typed so far
+}
+
+// isKeyAction tells whether there is an action with the name
+func isKeyAction(name string) bool {
+	for _, action := range keyActions {
+		if action.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// newKeymap creates a keymap from the preset with the overrides applied.
+// The overrides map key sequences like "Ctrl-J" or "g g" to the names of
+// actions, and binding a key to "none" removes it from the preset.
+// An empty preset is the default preset. If some of the overrides are bad,
+// the keymap is still returned along with an error that lists them.
+func newKeymap(preset string, overrides map[string]string) (*keymap, error) {
+	if preset == "" {
+		preset = "default"
+	}
+	presetBindings, ok := keymapPresets[preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown keymap %q, expected default, vim or emacs", preset)
+	}
+
+	km := &keymap{
+		preset:   preset,
+		bindings: make(map[string]string),
+	}
+	for keys, action := range presetBindings {
+		km.bindings[keys] = action
+	}
+
+	var problems []string
+	for keys, action := range overrides {
+		keys = strings.Join(strings.Fields(keys), " ")
+		action = strings.TrimSpace(action)
+		if action == noAction {
+			delete(km.bindings, keys)
+		} else if isKeyAction(action) {
+			km.bindings[keys] = action
+		} else {
+			problems = append(problems, fmt.Sprintf("unknown action %q for %q", action, keys))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return km, fmt.Errorf("bad key bindings: %s", strings.Join(problems, ", "))
+	}
+	return km, nil
+}
+
+// keyName returns the name of the key as it is written in keymaps
+func keyName(event *tcell.EventKey) string {
+	var name string
+	if event.Key() == tcell.KeyRune {
+		if event.Rune() == ' ' {
+			name = "Space"
+		} else {
+			name = string(event.Rune())
+		}
+	} else if keyName, ok := tcell.KeyNames[event.Key()]; ok {
+		name = keyName
+	} else {
+		name = fmt.Sprintf("Key[%d]", event.Key())
+	}
+	if event.Modifiers()&tcell.ModAlt != 0 {
+		name = "Alt-" + name
+	}
+	return name
+}
+
+// isPrefix tells whether the key sequence is the start
+// of a longer key sequence that has an action
+func (km *keymap) isPrefix(sequence string) bool {
+	for keys := range km.bindings {
+		if strings.HasPrefix(keys, sequence+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// handleKey looks up the action for the key, taking into account the keys
+// of a sequence typed before it. It returns the name of the action, which
+// is empty if there is none yet, and whether the key was used by the keymap.
+func (km *keymap) handleKey(event *tcell.EventKey) (string, bool) {
+	name := keyName(event)
+	sequence := strings.Join(append(km.pending, name), " ")
+
+	if action, ok := km.bindings[sequence]; ok {
+		km.pending = nil
+		return action, true
+	}
+	if km.isPrefix(sequence) {
+		km.pending = append(km.pending, name)
+		return "", true
+	}
+
+	// The sequence went nowhere, so try the key on its own
+	hadPending := len(km.pending) > 0
+	km.pending = nil
+	if hadPending {
+		if action, ok := km.bindings[name]; ok {
+			return action, true
+		}
+		if km.isPrefix(name) {
+			km.pending = []string{name}
+			return "", true
+		}
+	}
+	return "", false
+}
+
+// helpText lists every action with the keys bound to it in the keymap
+func (km *keymap) helpText() string {
+	keysByAction := make(map[string][]string)
+	for keys, action := range km.bindings {
+		keysByAction[action] = append(keysByAction[action], keys)
+	}
+
+	var help strings.Builder
+	fmt.Fprintf(&help, "keymap: %s\n\n", km.preset)
+	for _, action := range keyActions {
+		keys := keysByAction[action.name]
+		if len(keys) == 0 {
+			continue
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(&help, "%-26s %s\n", strings.Join(keys, ", "), action.description)
+	}
+	return help.String()
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell"
+)
+
+// pressKeys sends the keys, written as in keymaps, to the keymap one at a
+// time and prints what each one does
+func pressKeys(km *keymap, keys string) {
+	var results []string
+	for _, name := range strings.Fields(keys) {
+		event := tcell.NewEventKey(tcell.KeyRune, []rune(name)[0], tcell.ModNone)
+		for key, keyName := range tcell.KeyNames {
+			if keyName == name {
+				event = tcell.NewEventKey(key, 0, tcell.ModNone)
+			}
+		}
+		action, handled := km.handleKey(event)
+		switch {
+		case action != "":
+			results = append(results, action)
+		case handled:
+			results = append(results, "…")
+		default:
+			results = append(results, "-")
+		}
+	}
+	fmt.Println(keys+":", strings.Join(results, " "))
+}
+
+func Example_keymap_handleKey() {
+	vim, _ := newKeymap("vim", nil)
+	pressKeys(vim, "g g")
+	pressKeys(vim, "g j")
+	pressKeys(vim, "Ctrl-W o Ctrl-W W")
+	pressKeys(vim, "Z x")
+	pressKeys(vim, "Z Z")
+
+	emacs, _ := newKeymap("emacs", nil)
+	pressKeys(emacs, "Ctrl-X r m")
+	pressKeys(emacs, "Ctrl-X Ctrl-X 1")
+	pressKeys(emacs, "Ctrl-X Ctrl-C")
+
+	defaults, _ := newKeymap("", nil)
+	pressKeys(defaults, "Tab g m")
+
+	custom, err := newKeymap("vim", map[string]string{"g g": "none", "Q": "quit", "x": "explode", "Ctrl-W  x": "zoom-pane"})
+	fmt.Println(err)
+	pressKeys(custom, "g g Q Ctrl-W x")
+
+	_, err = newKeymap("nano", nil)
+	fmt.Println(err)
+
+	// Output: g g: … head
+	// g j: … step-forward
+	// Ctrl-W o Ctrl-W W: … zoom-pane … focus-prev-pane
+	// Z x: … -
+	// Z Z: … quit
+	// Ctrl-X r m: … … add-mark
+	// Ctrl-X Ctrl-X 1: … … zoom-pane
+	// Ctrl-X Ctrl-C: … quit
+	// Tab g m: step-forward - add-mark
+	// bad key bindings: unknown action "explode" for "x"
+	// g g Q Ctrl-W x: - - quit … zoom-pane
+	// unknown keymap "nano", expected default, vim or emacs
+}
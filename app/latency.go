@@ -0,0 +1,336 @@
+package app
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// maxLatencyEvents is the most events whose latencies are listed one by
+// one below the summary
+const maxLatencyEvents = 500
+
+// latencyPercentiles are the percentiles of the latencies in the summary
+var latencyPercentiles = []int{50, 90, 99}
+
+// latencyEvent is an event, like a block or transaction, and the time that
+// every file first logged it
+type latencyEvent struct {
+	id    string  // The ID of the event, from the group of the pattern
+	times []int64 // The time every file first logged the event, with its clock offset, or 0 if it did not
+	first int     // The index of the file that logged the event first
+}
+
+// latency returns how long after the first file the file logged the event,
+// and whether it did
+func (e *latencyEvent) latency(file int) (time.Duration, bool) {
+	if e.times[file] == 0 {
+		return 0, false
+	}
+	return time.Duration(e.times[file] - e.times[e.first]), true
+}
+
+// compileLatencyPattern compiles the event pattern, which needs a group
+// that captures the ID of the event
+func compileLatencyPattern(text string) (*regexp.Regexp, error) {
+	pattern, err := regexp.Compile(text)
+	if err != nil {
+		return nil, fmt.Errorf("bad regular expression: %v", err)
+	}
+	if pattern.NumSubexp() < 1 {
+		return nil, fmt.Errorf("the pattern needs a group that captures the ID, like height=(\\d+)")
+	}
+	return pattern, nil
+}
+
+// scanLatencyHits reads the whole file and finds the first line of every
+// event that matches the pattern, by the ID the first group captures, and
+// returns the time of each
+func scanLatencyHits(file io.ReaderAt, pattern *regexp.Regexp) (map[string]int64, error) {
+	hits := make(map[string]int64)
+	reader := bufio.NewReader(io.NewSectionReader(file, 0, math.MaxInt64))
+	var lastTime int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if lineTime := filechunk.GetTimeStampFromLine(string(line)); lineTime > 1 {
+				lastTime = lineTime
+			}
+			if match := pattern.FindSubmatch(line); match != nil {
+				if _, seen := hits[string(match[1])]; !seen && lastTime > 0 {
+					hits[string(match[1])] = lastTime
+				}
+			}
+		}
+		if err == io.EOF {
+			return hits, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// findLatencies scans all the files at once for the first line of every
+// event in each, and returns the events in the order they first happened.
+// The clock offset of each file is added to its times first, so that files
+// from machines whose clocks do not agree can be compared.
+func findLatencies(files []io.ReaderAt, pattern *regexp.Regexp, clockOffsets []time.Duration) ([]*latencyEvent, error) {
+	found := make([]map[string]int64, len(files))
+	errs := make([]error, len(files))
+	var wg sync.WaitGroup
+	for i := range files {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			found[i], errs[i] = scanLatencyHits(files[i], pattern)
+		}(i)
+	}
+	wg.Wait()
+
+	events := make(map[string]*latencyEvent)
+	for i := range files {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		for id, hitTime := range found[i] {
+			e := events[id]
+			if e == nil {
+				e = &latencyEvent{id: id, times: make([]int64, len(files))}
+				events[id] = e
+			}
+			e.times[i] = hitTime
+			if i < len(clockOffsets) {
+				e.times[i] += int64(clockOffsets[i])
+			}
+		}
+	}
+
+	sorted := make([]*latencyEvent, 0, len(events))
+	for _, e := range events {
+		e.first = -1
+		for i, t := range e.times {
+			if t != 0 && (e.first < 0 || t < e.times[e.first]) {
+				e.first = i
+			}
+		}
+		sorted = append(sorted, e)
+	}
+	sort.Slice(sorted, func(a, b int) bool {
+		timeA, timeB := sorted[a].times[sorted[a].first], sorted[b].times[sorted[b].first]
+		if timeA != timeB {
+			return timeA < timeB
+		}
+		return sorted[a].id < sorted[b].id
+	})
+	return sorted, nil
+}
+
+// percentile returns the pth percentile of the sorted durations, by the
+// nearest rank
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// latencyRow is a row of the summary: how many events the files logged,
+// how often one of them was first, and the percentiles of their latencies
+type latencyRow struct {
+	label     string
+	events    int
+	first     int // How many of the events the file was first to log, or -1 for the row of all the files
+	latencies []time.Duration
+}
+
+// writeTo writes the row to the summary, with the label padded to labelWidth
+func (row *latencyRow) writeTo(summary io.Writer, labelWidth int) {
+	sort.Slice(row.latencies, func(a, b int) bool { return row.latencies[a] < row.latencies[b] })
+	first := "-"
+	if row.first >= 0 {
+		first = strconv.Itoa(row.first)
+	}
+	fmt.Fprintf(summary, "%-*s %7d %7s", labelWidth, row.label, row.events, first)
+	for _, p := range latencyPercentiles {
+		fmt.Fprintf(summary, " %10s", formatLatency(percentile(row.latencies, p)))
+	}
+	fmt.Fprintf(summary, " %10s\n", formatLatency(percentile(row.latencies, 100)))
+}
+
+// formatLatency shows a latency rounded to the microsecond
+func formatLatency(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
+
+// describeLatencies returns the summary of the latencies: for every file,
+// how many of the events it logged, how many it logged first and the
+// percentiles of how long after the first file it logged them, followed by
+// the latencies of each event
+func describeLatencies(fileNames []string, pattern *regexp.Regexp, clockOffsets []time.Duration, events []*latencyEvent) string {
+	var summary strings.Builder
+	if len(events) == 0 {
+		fmt.Fprintf(&summary, "no line of any file matches %s\n", pattern)
+		return summary.String()
+	}
+
+	names := uniqueNames(fileNames)
+	// The labels of the rows and the first column also hold "all" and
+	// "first", and the columns of the files also hold latencies
+	labelWidth := longestName(append([]string{"first"}, names...))
+	columnWidth := longestName(names)
+	if columnWidth < 10 {
+		columnWidth = 10
+	}
+
+	fmt.Fprintf(&summary, "%s of %s, latency after the first file to log each\n", countOf(len(events), "event"), pattern)
+	for i, offset := range clockOffsets {
+		if offset != 0 {
+			fmt.Fprintf(&summary, "clock offset of %s: %s\n", names[i], offset)
+		}
+	}
+	fmt.Fprintf(&summary, "\n%-*s %7s %7s", labelWidth, "", "events", "first")
+	for _, p := range latencyPercentiles {
+		fmt.Fprintf(&summary, " %10s", "p"+strconv.Itoa(p))
+	}
+	fmt.Fprintf(&summary, " %10s\n", "max")
+
+	all := &latencyRow{label: "all"}
+	for i := range fileNames {
+		row := &latencyRow{label: names[i]}
+		for _, e := range events {
+			if d, ok := e.latency(i); ok {
+				row.events++
+				row.latencies = append(row.latencies, d)
+				if e.first == i {
+					row.first++
+				} else {
+					all.latencies = append(all.latencies, d)
+				}
+			}
+		}
+		row.writeTo(&summary, labelWidth)
+	}
+	all.events, all.first = len(events), -1
+	all.writeTo(&summary, labelWidth)
+	fmt.Fprintf(&summary, "(all leaves out the file that was first for each event)\n\n")
+
+	fmt.Fprintf(&summary, "%-16s %-*s", "id", labelWidth, "first")
+	for _, name := range names {
+		fmt.Fprintf(&summary, " %*s", columnWidth, name)
+	}
+	fmt.Fprintf(&summary, "\n")
+	for n, e := range events {
+		if n == maxLatencyEvents {
+			fmt.Fprintf(&summary, "... and %d more\n", len(events)-maxLatencyEvents)
+			break
+		}
+		id := []rune(e.id)
+		if len(id) > 16 {
+			id = append(id[:15], '…')
+		}
+		fmt.Fprintf(&summary, "%-16s %-*s", string(id), labelWidth, names[e.first])
+		for i := range fileNames {
+			text := "-"
+			if d, ok := e.latency(i); ok {
+				text = formatLatency(d)
+			}
+			fmt.Fprintf(&summary, " %*s", columnWidth, text)
+		}
+		fmt.Fprintf(&summary, "\n")
+	}
+	return summary.String()
+}
+
+// exportLatencies writes the latencies as CSV, with a row for every event
+// that has its ID, the file that logged it first and the latency of every
+// file in milliseconds, empty for the files that did not log it
+func exportLatencies(filename string, fileNames []string, events []*latencyEvent) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(file)
+	names := uniqueNames(fileNames)
+	w.Write(append([]string{"id", "first"}, names...))
+	for _, e := range events {
+		record := []string{e.id, names[e.first]}
+		for i := range fileNames {
+			value := ""
+			if d, ok := e.latency(i); ok {
+				value = strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+			}
+			record = append(record, value)
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// parseClockOffsets reads the clock offsets of the config, which are
+// durations like "-150ms" added to the times of the files they are given
+// for, by file name or #N
+func parseClockOffsets(fileViews []fileView, config map[string]string) ([]time.Duration, []error) {
+	clockOffsets := make([]time.Duration, len(fileViews))
+	var errs []error
+	for ref, text := range config {
+		i, err := resolveFileRef(fileViews, ref)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("bad clock offset: %v", err))
+			continue
+		}
+		offset, err := time.ParseDuration(text)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("bad clock offset %q for %s, expected a duration like -150ms", text, ref))
+			continue
+		}
+		clockOffsets[i] = offset
+	}
+	return clockOffsets, errs
+}
+
+// clockOffsetConfig returns the clock offsets of the files that have one,
+// by file name, as they are written in the config and session files
+func clockOffsetConfig(fileViews []fileView, clockOffsets []time.Duration) map[string]string {
+	config := make(map[string]string)
+	for i, offset := range clockOffsets {
+		if offset != 0 {
+			config[uniqueNames(logFilenames(fileViews))[i]] = offset.String()
+		}
+	}
+	return config
+}
+
+// describeClockOffsets lists the clock offsets of the files that have one
+func describeClockOffsets(fileViews []fileView, clockOffsets []time.Duration) string {
+	var parts []string
+	for i, offset := range clockOffsets {
+		if offset != 0 {
+			parts = append(parts, fmt.Sprintf("%s %s", uniqueNames(logFilenames(fileViews))[i], offset))
+		}
+	}
+	if len(parts) == 0 {
+		return "no clock offsets"
+	}
+	return "clock offsets: " + strings.Join(parts, ", ")
+}
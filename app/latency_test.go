@@ -0,0 +1,45 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+func Example_describeLatencies() {
+	logs := []string{
+		"I[2020-05-25|08:45:33.100] Committed block height=1\n" +
+			"I[2020-05-25|08:45:34.100] Committed block height=2\n",
+		"I[2020-05-25|08:45:33.150] Committed block height=1\n" +
+			"I[2020-05-25|08:45:33.160] Committed block height=1\n" +
+			"I[2020-05-25|08:45:34.090] Committed block height=2\n",
+		"I[2020-05-25|08:45:33.400] Committed block height=1\n",
+	}
+	files := make([]io.ReaderAt, len(logs))
+	for i := range logs {
+		files[i] = strings.NewReader(logs[i])
+	}
+	pattern, _ := compileLatencyPattern(`Committed block height=(\d+)`)
+	clockOffsets := []time.Duration{0, 0, -200 * time.Millisecond}
+	events, _ := findLatencies(files, pattern, clockOffsets)
+	fmt.Print(describeLatencies([]string{"logs/node0-json.log", "logs/node1-json.log", "logs/node2-json.log"}, pattern, clockOffsets, events))
+
+	_, err := compileLatencyPattern(`Committed block`)
+	fmt.Println(err)
+
+	// Output: 2 events of Committed block height=(\d+), latency after the first file to log each
+	// clock offset of node2-json.log: -200ms
+	//
+	//                 events   first        p50        p90        p99        max
+	// node0-json.log       2       1         0s       10ms       10ms       10ms
+	// node1-json.log       2       1         0s       50ms       50ms       50ms
+	// node2-json.log       1       0      100ms      100ms      100ms      100ms
+	// all                  2       -       50ms      100ms      100ms      100ms
+	// (all leaves out the file that was first for each event)
+	//
+	// id               first          node0-json.log node1-json.log node2-json.log
+	// 1                node0-json.log             0s           50ms          100ms
+	// 2                node1-json.log           10ms             0s              -
+	// the pattern needs a group that captures the ID, like height=(\d+)
+}
@@ -0,0 +1,173 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// The ways the file views can be arranged
+const (
+	arrangeRows    = "rows"    // every file view is a row, stacked on top of each other
+	arrangeColumns = "columns" // every file view is a column, side by side
+	arrangeGrid    = "grid"    // the file views are in a grid that is as square as possible
+)
+
+// paneLayout describes how the file views are arranged on the screen.
+// Hidden file views are not shown but still move with the rest of the files.
+// The fields are exported so the layout can be saved with a session.
+type paneLayout struct {
+	Arrangement string `json:"arrangement"` // One of arrangeRows, arrangeColumns or arrangeGrid
+	Zoomed      int    `json:"zoomed"`      // The index of the file view shown full screen, or -1 if none is
+	Hidden      []bool `json:"hidden"`      // Whether each file view is hidden
+	Weights     []int  `json:"weights"`     // The relative size of each file view
+}
+
+// newPaneLayout creates the default layout, which stacks all the
+// file views on top of each other in equal heights.
+func newPaneLayout(numFiles int) *paneLayout {
+	layout := &paneLayout{
+		Arrangement: arrangeRows,
+		Zoomed:      -1,
+		Hidden:      make([]bool, numFiles),
+		Weights:     make([]int, numFiles),
+	}
+	for i := range layout.Weights {
+		layout.Weights[i] = 1
+	}
+	return layout
+}
+
+// visibleIndices returns the indices of the file views that are shown
+func (pl *paneLayout) visibleIndices() []int {
+	if pl.Zoomed >= 0 && pl.Zoomed < len(pl.Hidden) {
+		return []int{pl.Zoomed}
+	}
+	var visible []int
+	for i, hidden := range pl.Hidden {
+		if !hidden {
+			visible = append(visible, i)
+		}
+	}
+	return visible
+}
+
+// arrange clears the container and adds the visible file views to it
+// according to the layout.
+func (pl *paneLayout) arrange(container *tview.Flex, fileViews []fileView) {
+	container.Clear()
+	visible := pl.visibleIndices()
+
+	switch pl.Arrangement {
+	case arrangeColumns:
+		container.SetDirection(tview.FlexColumn)
+		for _, i := range visible {
+			container.AddItem(&fileViews[i], 0, pl.Weights[i], false)
+		}
+	case arrangeGrid:
+		container.SetDirection(tview.FlexRow)
+		numColumns := int(math.Ceil(math.Sqrt(float64(len(visible)))))
+		for start := 0; start < len(visible); start += numColumns {
+			row := tview.NewFlex().SetDirection(tview.FlexColumn)
+			rowWeight := 0
+			for _, i := range visible[start:minInt(start+numColumns, len(visible))] {
+				row.AddItem(&fileViews[i], 0, pl.Weights[i], false)
+				if pl.Weights[i] > rowWeight {
+					rowWeight = pl.Weights[i]
+				}
+			}
+			container.AddItem(row, 0, rowWeight, false)
+		}
+	default:
+		container.SetDirection(tview.FlexRow)
+		for _, i := range visible {
+			container.AddItem(&fileViews[i], 0, pl.Weights[i], false)
+		}
+	}
+}
+
+// minInt returns the smaller of the two ints
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// parsePaneNumber parses the number of a file view as shown in its title,
+// which starts at 1, and returns its index.
+func parsePaneNumber(arg string, numFiles int) (int, error) {
+	paneNumber, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil || paneNumber < 1 || paneNumber > numFiles {
+		return -1, fmt.Errorf("bad pane number %q, expected 1 to %d", arg, numFiles)
+	}
+	return paneNumber - 1, nil
+}
+
+// applyLayoutCommand changes the layout according to a layout command.
+// The commands are:
+// "layout rows", "layout columns" or "layout grid" to arrange the file views,
+// "zoom N" to show only file view N, or "zoom" to show them all again,
+// "hide N" and "show N" to hide or show file view N, or "show all",
+// "weight N W" to make file view N take W times the space of a weight 1 view.
+func (pl *paneLayout) applyLayoutCommand(command string, args []string) error {
+	numFiles := len(pl.Hidden)
+	switch command {
+	case "layout":
+		if len(args) != 1 || (args[0] != arrangeRows && args[0] != arrangeColumns && args[0] != arrangeGrid) {
+			return fmt.Errorf("expected layout rows, columns or grid")
+		}
+		pl.Arrangement = args[0]
+	case "zoom":
+		if len(args) == 0 {
+			pl.Zoomed = -1
+			return nil
+		}
+		index, err := parsePaneNumber(args[0], numFiles)
+		if err != nil {
+			return err
+		}
+		if pl.Zoomed == index {
+			pl.Zoomed = -1
+		} else {
+			pl.Zoomed = index
+		}
+	case "hide", "show":
+		if len(args) != 1 {
+			return fmt.Errorf("expected %s N", command)
+		}
+		if command == "show" && args[0] == "all" {
+			for i := range pl.Hidden {
+				pl.Hidden[i] = false
+			}
+			return nil
+		}
+		index, err := parsePaneNumber(args[0], numFiles)
+		if err != nil {
+			return err
+		}
+		pl.Hidden[index] = command == "hide"
+		if pl.Zoomed == index && pl.Hidden[index] {
+			pl.Zoomed = -1
+		}
+	case "weight":
+		if len(args) != 2 {
+			return fmt.Errorf("expected weight N W")
+		}
+		index, err := parsePaneNumber(args[0], numFiles)
+		if err != nil {
+			return err
+		}
+		weight, err := strconv.Atoi(args[1])
+		if err != nil || weight < 1 {
+			return fmt.Errorf("bad weight %q, expected a whole number of at least 1", args[1])
+		}
+		pl.Weights[index] = weight
+	default:
+		return fmt.Errorf("unknown layout command %q", command)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+func Example_paneLayout_applyLayoutCommand() {
+	layout := newPaneLayout(4)
+	for _, line := range []string{
+		"layout columns",
+		"weight 2 3",
+		"hide 3",
+		"zoom 2",
+		"zoom 2",
+		"zoom 1",
+		"hide 1",
+		"show all",
+		"layout stacked",
+		"weight 2 0",
+		"hide 5",
+		"split 1",
+	} {
+		words := strings.Fields(line)
+		if err := layout.applyLayoutCommand(words[0], words[1:]); err != nil {
+			fmt.Println(line, "error:", err)
+			continue
+		}
+		fmt.Println(line, layout.Arrangement, layout.visibleIndices(), layout.Weights)
+	}
+
+	log := "I[2020-05-25|08:45:30.000] Starting\nI[2020-05-25|08:45:31.000] Started\n"
+	fileViews := openLogFileViews(log, log, log, log)
+	container := tview.NewFlex()
+	screen := tcell.NewSimulationScreen("")
+	screen.Init()
+	for _, arrangement := range []string{arrangeRows, arrangeGrid} {
+		layout.Arrangement = arrangement
+		layout.arrange(container, fileViews)
+		container.SetRect(0, 0, 60, 30)
+		container.Draw(screen)
+		for i := range fileViews {
+			x, y, width, height := fileViews[i].GetRect()
+			fmt.Println(arrangement, i+1, x, y, width, height)
+		}
+	}
+
+	// Output: layout columns columns [0 1 2 3] [1 1 1 1]
+	// weight 2 3 columns [0 1 2 3] [1 3 1 1]
+	// hide 3 columns [0 1 3] [1 3 1 1]
+	// zoom 2 columns [1] [1 3 1 1]
+	// zoom 2 columns [0 1 3] [1 3 1 1]
+	// zoom 1 columns [0] [1 3 1 1]
+	// hide 1 columns [1 3] [1 3 1 1]
+	// show all columns [0 1 2 3] [1 3 1 1]
+	// layout stacked error: expected layout rows, columns or grid
+	// weight 2 0 error: bad weight "0", expected a whole number of at least 1
+	// hide 5 error: bad pane number "5", expected 1 to 4
+	// split 1 error: unknown layout command "split"
+	// rows 1 0 0 60 5
+	// rows 2 0 5 60 15
+	// rows 3 0 20 60 5
+	// rows 4 0 25 60 5
+	// grid 1 0 0 15 22
+	// grid 2 15 0 45 22
+	// grid 3 0 22 30 8
+	// grid 4 30 22 30 8
+}
@@ -0,0 +1,176 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// autoMarkPrefix starts the names of marks that are set without a name
+const autoMarkPrefix = "m"
+
+// markTimeFormat is how the time of a mark is shown when marks are listed
+const markTimeFormat = "15:04:05.000"
+
+// mark is a named moment of an investigation, like "leader-elected", that
+// can be jumped back to. It records the current line of every file, so
+// jumping back puts every file exactly where it was, not just at the
+// closest line in time. The offsets of the lines are kept as well so the
+// mark can be saved with a session and found again in the files.
+type mark struct {
+	Name    string                 `json:"name"`    // The name the mark is set and jumped to with
+	Time    int64                  `json:"time"`    // The time of the cluster when the mark was set
+	Offsets []int64                `json:"offsets"` // The offset of the current line of every file
+	chunks  []*filechunk.FileChunk // The current line of every file, if it has been loaded
+}
+
+// markList is the list of marks, in the order they were set
+type markList struct {
+	marks    []*mark // The marks, in the order they were set
+	nextAuto int     // The number of the next mark that is set without a name
+}
+
+// newMarkList creates an empty list of marks
+func newMarkList() *markList {
+	return &markList{nextAuto: 1}
+}
+
+// find returns the mark with the name, or nil if there is none
+func (ml *markList) find(name string) *mark {
+	for _, m := range ml.marks {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// names returns the names of all the marks
+func (ml *markList) names() []string {
+	names := make([]string, len(ml.marks))
+	for i, m := range ml.marks {
+		names[i] = m.Name
+	}
+	return names
+}
+
+// set records the current position of all the fileViews under the name,
+// replacing any mark with the same name. An empty name picks the next
+// unused name like "m1".
+func (ml *markList) set(name string, fileViews []fileView) *mark {
+	if name == "" {
+		for name == "" || ml.find(name) != nil {
+			name = fmt.Sprintf("%s%d", autoMarkPrefix, ml.nextAuto)
+			ml.nextAuto++
+		}
+	}
+
+	m := &mark{
+		Name:    name,
+		Time:    CurrentClusterTime(fileViews),
+		Offsets: make([]int64, len(fileViews)),
+		chunks:  currentChunks(fileViews),
+	}
+	for i, chunk := range m.chunks {
+		m.Offsets[i] = chunk.FileOffsetStart
+	}
+
+	if old := ml.find(name); old != nil {
+		*old = *m
+		return old
+	}
+	ml.marks = append(ml.marks, m)
+	return m
+}
+
+// remove deletes the mark with the name and tells whether there was one
+func (ml *markList) remove(name string) bool {
+	for i, m := range ml.marks {
+		if m.Name == name {
+			ml.marks = append(ml.marks[:i], ml.marks[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// resolveChunks finds the lines of the mark in the files from their offsets,
+// which is needed for marks that were loaded from a session file.
+func (m *mark) resolveChunks(fileViews []fileView) {
+	if len(m.chunks) == len(fileViews) {
+		return
+	}
+	m.chunks = make([]*filechunk.FileChunk, len(fileViews))
+	for i := range fileViews {
+		if i < len(m.Offsets) {
+			m.chunks[i] = fileViews[i].currChunk.GetFileChunkAtOffset(m.Offsets[i])
+		}
+	}
+}
+
+// jumpTo moves every file back to the line it was on when the mark was set
+func (ml *markList) jumpTo(m *mark, fileViews []fileView) {
+	m.resolveChunks(fileViews)
+	restorePosition(fileViews, m.chunks)
+}
+
+// adjacent returns the mark with the closest time after the time, or before
+// it if forward is false, or nil if there is none. Marks at the same time
+// are taken in the order they were set.
+func (ml *markList) adjacent(currTime int64, forward bool) *mark {
+	var found *mark
+	for _, m := range ml.marks {
+		if forward && m.Time > currTime && (found == nil || m.Time < found.Time) {
+			found = m
+		}
+		if !forward && m.Time < currTime && (found == nil || m.Time > found.Time) {
+			found = m
+		}
+	}
+	return found
+}
+
+// describe lists the marks in time order with how long after
+// the one before it each mark is.
+func (ml *markList) describe() string {
+	if len(ml.marks) == 0 {
+		return "no marks"
+	}
+	sorted := append([]*mark(nil), ml.marks...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return sorted[a].Time < sorted[b].Time
+	})
+
+	var parts []string
+	for i, m := range sorted {
+		part := m.Name + " " + time.Unix(0, m.Time).UTC().Format(markTimeFormat)
+		if i > 0 {
+			part += " (+" + time.Duration(m.Time-sorted[i-1].Time).String() + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// measure returns how much time passes from the mark named from to the mark
+// named to, or to the current position if to is empty.
+func (ml *markList) measure(from string, to string, fileViews []fileView) (string, error) {
+	fromMark := ml.find(from)
+	if fromMark == nil {
+		return "", fmt.Errorf("no mark %q", from)
+	}
+
+	toName := "now"
+	toTime := CurrentClusterTime(fileViews)
+	if to != "" {
+		toMark := ml.find(to)
+		if toMark == nil {
+			return "", fmt.Errorf("no mark %q", to)
+		}
+		toName, toTime = toMark.Name, toMark.Time
+	}
+	return fmt.Sprintf("%s to %s: %s", fromMark.Name, toName, time.Duration(toTime-fromMark.Time)), nil
+}
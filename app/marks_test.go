@@ -0,0 +1,47 @@
+package app
+
+import (
+	"fmt"
+	"time"
+)
+
+func Example_markList() {
+	fileViews := openLogFileViews(
+		"I[2020-05-25|08:45:30.000] a\n"+
+			"I[2020-05-25|08:45:31.500] b\n"+
+			"I[2020-05-25|08:45:34.000] c\n",
+		"I[2020-05-25|08:45:30.500] a\n"+
+			"I[2020-05-25|08:45:33.000] b\n",
+	)
+	marks := newMarkList()
+	marks.set("", fileViews)
+	StepAllByDuration(fileViews, 2*time.Second)
+	marks.set("elected", fileViews)
+	StepAllByDuration(fileViews, 2*time.Second)
+	marks.set("", fileViews)
+	fmt.Println(marks.names())
+	fmt.Println(marks.describe())
+
+	fmt.Println(marks.measure("m1", "elected", fileViews))
+	fmt.Println(marks.measure("elected", "", fileViews))
+	_, err := marks.measure("m3", "", fileViews)
+	fmt.Println(err)
+
+	marks.jumpTo(marks.find("elected"), fileViews)
+	fmt.Println(fileViews[0].currChunk.FileOffsetStart, fileViews[1].currChunk.FileOffsetStart)
+	fmt.Println(marks.adjacent(CurrentClusterTime(fileViews), true).Name, marks.adjacent(CurrentClusterTime(fileViews), false).Name)
+
+	fmt.Println(marks.remove("elected"), marks.remove("elected"), marks.names())
+	marks.set("", fileViews)
+	fmt.Println(marks.describe())
+
+	// Output: [m1 elected m2]
+	// m1 08:45:30.500, elected 08:45:31.500 (+1s), m2 08:45:33.000 (+1.5s)
+	// m1 to elected: 1s <nil>
+	// elected to now: 1.5s <nil>
+	// no mark "m3"
+	// 29 0
+	// m2 m1
+	// true false [m1 m2]
+	// m1 08:45:30.500, m3 08:45:31.500 (+1s), m2 08:45:33.000 (+1.5s)
+}
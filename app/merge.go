@@ -0,0 +1,128 @@
+package app
+
+import (
+	"sort"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// mergedLine is one log line in the merged, time ordered sequence
+// of the log lines of all the files.
+type mergedLine struct {
+	index int                  // The index of the fileView that the log line is from
+	chunk *filechunk.FileChunk // The log line
+}
+
+// currentChunks returns the current chunk of every fileView
+func currentChunks(fileViews []fileView) []*filechunk.FileChunk {
+	chunks := make([]*filechunk.FileChunk, len(fileViews))
+	for i := range fileViews {
+		chunks[i] = fileViews[i].currChunk
+	}
+	return chunks
+}
+
+// stepMergeForward moves the cursors forward by one log line in time order,
+// like AdvanceNextFileViewForward: the file with the earliest next log line
+// goes first. The cursors have no scroll times, so unlike stepping, where
+// ties go to the file that was scrolled least recently, ties go to the file
+// with the lowest index. It returns the index of the file that moved and its
+// new log line, or -1 and nil if every file is at its end.
+func stepMergeForward(cursors []*filechunk.FileChunk) (int, *filechunk.FileChunk) {
+	minIndex := -1
+	var minChunk *filechunk.FileChunk
+	for i := range cursors {
+		nextChunk := cursors[i].GetNextFileChunk()
+		if nextChunk == nil {
+			continue
+		}
+		if minChunk == nil || nextChunk.LineTimeStamp < minChunk.LineTimeStamp {
+			minIndex = i
+			minChunk = nextChunk
+		}
+	}
+	if minIndex > -1 {
+		cursors[minIndex] = minChunk
+	}
+	return minIndex, minChunk
+}
+
+// stepMergeBackward moves the cursors backward by one log line in time
+// order, like AdvancePrevFileViewBackward, with ties going to the file with
+// the lowest index. It returns the index of the file that moved and its new
+// log line, or -1 and nil if every file is at its beginning.
+func stepMergeBackward(cursors []*filechunk.FileChunk) (int, *filechunk.FileChunk) {
+	maxIndex := -1
+	var maxChunk *filechunk.FileChunk
+	for i := range cursors {
+		prevChunk := cursors[i].GetPrevFileChunk()
+		if prevChunk == nil {
+			continue
+		}
+		if maxChunk == nil || prevChunk.LineTimeStamp > maxChunk.LineTimeStamp {
+			maxIndex = i
+			maxChunk = prevChunk
+		}
+	}
+	if maxIndex > -1 {
+		cursors[maxIndex] = maxChunk
+	}
+	return maxIndex, maxChunk
+}
+
+// mergeForward returns up to count log lines that come after the given
+// chunks in merged order. The given chunks are not changed.
+func mergeForward(chunks []*filechunk.FileChunk, count int) []mergedLine {
+	cursors := append([]*filechunk.FileChunk(nil), chunks...)
+	var lines []mergedLine
+	for len(lines) < count {
+		index, chunk := stepMergeForward(cursors)
+		if index < 0 {
+			break
+		}
+		lines = append(lines, mergedLine{index: index, chunk: chunk})
+	}
+	return lines
+}
+
+// mergeBackward returns up to count log lines that come before the given
+// chunks in merged order, so the closest log line comes first.
+// The given chunks are not changed.
+func mergeBackward(chunks []*filechunk.FileChunk, count int) []mergedLine {
+	cursors := append([]*filechunk.FileChunk(nil), chunks...)
+	var lines []mergedLine
+	for len(lines) < count {
+		index, chunk := stepMergeBackward(cursors)
+		if index < 0 {
+			break
+		}
+		lines = append(lines, mergedLine{index: index, chunk: chunk})
+	}
+	return lines
+}
+
+// mergeAround returns the merged log lines around the current position of
+// all the fileViews: up to before lines leading up to it, the current line
+// of every file in time order, and up to after lines following it.
+// It also returns the position of the first current line in the result.
+func mergeAround(fileViews []fileView, before int, after int) ([]mergedLine, int) {
+	chunks := currentChunks(fileViews)
+
+	var lines []mergedLine
+	prevLines := mergeBackward(chunks, before)
+	for i := len(prevLines) - 1; i >= 0; i-- {
+		lines = append(lines, prevLines[i])
+	}
+
+	currStart := len(lines)
+	var currLines []mergedLine
+	for i, chunk := range chunks {
+		currLines = append(currLines, mergedLine{index: i, chunk: chunk})
+	}
+	sort.SliceStable(currLines, func(a, b int) bool {
+		return currLines[a].chunk.LineTimeStamp < currLines[b].chunk.LineTimeStamp
+	})
+	lines = append(lines, currLines...)
+
+	return append(lines, mergeForward(chunks, after)...), currStart
+}
@@ -0,0 +1,145 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// nodeColor returns the color name for the file at the index, from the node
+// colors of the theme, which repeat if there are more files than colors
+func nodeColor(index int) string {
+	return currentTheme.Nodes[index%len(currentTheme.Nodes)]
+}
+
+// longestName returns the width of the longest of the names, so that a
+// column of them can be lined up
+func longestName(names []string) int {
+	width := 0
+	for _, name := range names {
+		if nameWidth := utf8.RuneCountInString(name); nameWidth > width {
+			width = nameWidth
+		}
+	}
+	return width
+}
+
+// mergedView is a single pane that shows the log lines of all the files
+// merged in time order, each one prefixed with the name of its file in the
+// color of that file. The current line is the latest of the current lines
+// of all the files, which is the last line that stepping forward went past,
+// so stepping moves through the merged lines one at a time. The position is
+// shared with the file views, so switching back to them leaves each file
+// at the matching line.
+type mergedView struct {
+	*tview.TextView
+	fileViews    []fileView             // All the fileViews, whose current chunks are the position of the merged view
+	displayLines []mergedLine           // The merged lines currently loaded into the TextView
+	lastChunks   []*filechunk.FileChunk // The current chunks the last time the text was set
+	lastWidth    int                    // The inner width the last time the text was set
+	lastHeight   int                    // The inner height the last time the text was set
+	names        []string               // The names of the files, as uniqueNames gives them, which prefix the lines
+	prefixWidth  int                    // The width of the longest file name, which every prefix is padded to
+}
+
+// newMergedView creates the merged view for all the fileViews
+func newMergedView(fileViews []fileView) *mergedView {
+	mv := &mergedView{
+		TextView: tview.NewTextView().
+			SetDynamicColors(true).
+			SetRegions(true).
+			SetWordWrap(true),
+		fileViews: fileViews,
+		names:     uniqueNames(logFilenames(fileViews)),
+	}
+	mv.SetBorder(true).SetTitle("merged")
+
+	mv.prefixWidth = longestName(mv.names)
+
+	// Clicking on a line selects it and syncs all the files to its time
+	mv.SetHighlightedFunc(func(added, removed, remaining []string) {
+		if len(added) == 0 {
+			return
+		}
+		lineIndex, err := strconv.Atoi(added[0])
+		if err != nil || lineIndex < 0 || lineIndex >= len(mv.displayLines) {
+			return
+		}
+		line := mv.displayLines[lineIndex]
+		if line.chunk != mv.fileViews[line.index].currChunk {
+			SyncAllToFileChunk(mv.fileViews, line.index, line.chunk)
+		}
+	})
+
+	return mv
+}
+
+// positionChanged tells whether any file has moved or the view has been
+// resized since the text was last set.
+func (mv *mergedView) positionChanged(width int, height int) bool {
+	if width != mv.lastWidth || height != mv.lastHeight || len(mv.lastChunks) != len(mv.fileViews) {
+		return true
+	}
+	for i := range mv.fileViews {
+		if mv.fileViews[i].currChunk != mv.lastChunks[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// setMergedText fills the view with the merged lines around the current
+// position. Every line is its own region so that clicking selects it.
+func (mv *mergedView) setMergedText(width int, height int) {
+	mv.lastWidth, mv.lastHeight = width, height
+	mv.lastChunks = currentChunks(mv.fileViews)
+
+	linesAbove := (height - 1) * contextAbovePercent / 100
+	lines, currStart := mergeAround(mv.fileViews, linesAbove+scrollbackPages*height, height+scrollbackPages*height)
+	mv.displayLines = lines
+
+	// The current line is the last of the current lines of all the files
+	currLine := currStart + len(mv.fileViews) - 1
+
+	var text strings.Builder
+	for i, line := range lines {
+		prefix := fmt.Sprintf("%-*s", mv.prefixWidth, mv.names[line.index])
+		text.WriteString("[\"" + strconv.Itoa(i) + "\"][" + nodeColor(line.index) + "]" + tview.Escape(prefix) + "[-] ")
+		text.WriteString(renderLogLine(line.chunk.FileChunkBytes))
+		text.WriteString("[\"\"]")
+	}
+
+	mv.SetText(text.String())
+	mv.Highlight(strconv.Itoa(currLine))
+
+	var rowsBeforeCurr int
+	for _, line := range lines[:currLine] {
+		rowsBeforeCurr += rowsForLine(line.chunk.FileChunkBytes, width-mv.prefixWidth-1)
+	}
+	var rowsAbove int
+	for i := currLine - 1; i >= 0; i-- {
+		rows := rowsForLine(lines[i].chunk.FileChunkBytes, width-mv.prefixWidth-1)
+		if rowsAbove+rows > linesAbove {
+			break
+		}
+		rowsAbove += rows
+	}
+	mv.ScrollTo(rowsBeforeCurr-rowsAbove, 0)
+}
+
+// Draw refreshes the merged lines when the position has changed and then
+// draws the underlying TextView. When nothing has changed the text is left
+// alone, so the view can be scrolled without losing its place.
+func (mv *mergedView) Draw(screen tcell.Screen) {
+	_, _, width, height := mv.GetInnerRect()
+	if mv.positionChanged(width, height) {
+		mv.setMergedText(width, height)
+	}
+	mv.TextView.Draw(screen)
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// maxNavHistory is how many positions are kept to go back to
+const maxNavHistory = 100
+
+// largeStepLines is the number of lines a step command has to move
+// for it to count as a jump that is kept in the navigation history
+const largeStepLines = 10
+
+// navHistory keeps the positions of the cluster from before every jump, like
+// a time search, a search hit, jumping to a mark or to the head or tail, so
+// that they can be gone back to like the back and forward buttons of a web
+// browser. Stepping a line at a time does not change the history.
+type navHistory struct {
+	back    [][]*filechunk.FileChunk // The positions before each jump, most recent last
+	forward [][]*filechunk.FileChunk // The positions gone back from, most recent last
+}
+
+// samePosition tells whether the two positions have the same current chunks
+func samePosition(a []*filechunk.FileChunk, b []*filechunk.FileChunk) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// restorePosition makes the chunks the current chunks of the fileViews
+func restorePosition(fileViews []fileView, chunks []*filechunk.FileChunk) {
+	for i := range fileViews {
+		if i < len(chunks) && chunks[i] != nil {
+			fileViews[i].currChunk = chunks[i]
+		}
+		fileViews[i].SetDisplayText()
+	}
+}
+
+// record saves the current position before a jump. Jumping somewhere new
+// forgets the positions that were gone back from, like in a web browser.
+func (nh *navHistory) record(fileViews []fileView) {
+	position := currentChunks(fileViews)
+	if len(nh.back) > 0 && samePosition(nh.back[len(nh.back)-1], position) {
+		return
+	}
+	nh.back = append(nh.back, position)
+	if len(nh.back) > maxNavHistory {
+		nh.back = nh.back[1:]
+	}
+	nh.forward = nil
+}
+
+// moveThroughHistory goes to the last position of the from list, saving the
+// current position on the to list. Positions that are the same as the current
+// one, which are left by jumps that did not move, are skipped.
+func moveThroughHistory(fileViews []fileView, from *[][]*filechunk.FileChunk, to *[][]*filechunk.FileChunk) bool {
+	position := currentChunks(fileViews)
+	for len(*from) > 0 {
+		target := (*from)[len(*from)-1]
+		*from = (*from)[:len(*from)-1]
+		if samePosition(target, position) {
+			continue
+		}
+		*to = append(*to, position)
+		restorePosition(fileViews, target)
+		return true
+	}
+	return false
+}
+
+// goBack moves all the files back to where they were before the last jump
+// and tells whether there was anywhere to go back to.
+func (nh *navHistory) goBack(fileViews []fileView) bool {
+	return moveThroughHistory(fileViews, &nh.back, &nh.forward)
+}
+
+// goForward undoes goBack and tells whether there was anywhere to go forward to
+func (nh *navHistory) goForward(fileViews []fileView) bool {
+	return moveThroughHistory(fileViews, &nh.forward, &nh.back)
+}
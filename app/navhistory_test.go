@@ -0,0 +1,53 @@
+package app
+
+import (
+	"fmt"
+	"time"
+)
+
+func Example_navHistory() {
+	fileViews := openLogFileViews(
+		"I[2020-05-25|08:45:30.000] a\n" +
+			"I[2020-05-25|08:45:31.000] b\n" +
+			"I[2020-05-25|08:45:32.000] c\n" +
+			"I[2020-05-25|08:45:33.000] d\n",
+	)
+	base := time.Date(2020, 5, 25, 8, 45, 30, 0, time.UTC).UnixNano()
+	nav := &navHistory{}
+	jump := func(seconds int) {
+		nav.record(fileViews)
+		MoveAllToTime(fileViews, base+int64(seconds)*int64(time.Second))
+	}
+	line := func() string {
+		return string(fileViews[0].currChunk.FileChunkBytes[27:28])
+	}
+
+	jump(1)
+	jump(1)
+	jump(2)
+	fmt.Println(line(), len(nav.back))
+	fmt.Println(nav.goBack(fileViews), line())
+	fmt.Println(nav.goBack(fileViews), line())
+	fmt.Println(nav.goBack(fileViews), line())
+	fmt.Println(nav.goForward(fileViews), line())
+
+	jump(3)
+	fmt.Println(line(), nav.goForward(fileViews))
+	fmt.Println(nav.goBack(fileViews), line())
+	fmt.Println(nav.goBack(fileViews), line())
+
+	for i := 0; i < maxNavHistory+10; i++ {
+		jump(i % 4)
+	}
+	fmt.Println(len(nav.back), len(nav.forward))
+
+	// Output: c 2
+	// true b
+	// true a
+	// false a
+	// true b
+	// d false
+	// true b
+	// true a
+	// 100 0
+}
@@ -0,0 +1,271 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// The ways ANSI color sequences in the log lines can be shown
+const (
+	ansiColors = "colors" // the colors are shown like the terminal would show them
+	ansiStrip  = "strip"  // the sequences are removed and the lines are shown without colors
+)
+
+// ansiMode is how the ANSI color sequences from colorized loggers are shown
+var ansiMode = ansiColors
+
+// ansiColorNames are the tview names of the 16 basic ANSI colors,
+// normal ones first and then the bright ones.
+var ansiColorNames = []string{
+	"black", "maroon", "green", "olive", "navy", "purple", "teal", "silver",
+	"gray", "red", "lime", "yellow", "blue", "fuchsia", "aqua", "white",
+}
+
+// textStyle is the color and attributes that SGR sequences have set so far
+type textStyle struct {
+	fg    string // The foreground color as a tview color, or empty for the default
+	bg    string // The background color as a tview color, or empty for the default
+	attrs string // The tview attribute flags, like "bu" for bold and underlined
+}
+
+// tag returns the tview color tag that sets the style. The default
+// background is the background of the theme rather than the one of the
+// terminal.
+func (ts textStyle) tag() string {
+	fg, bg, attrs := ts.fg, ts.bg, ts.attrs
+	if fg == "" {
+		fg = "-"
+	}
+	if bg == "" {
+		bg = currentTheme.Background
+	}
+	if attrs == "" {
+		attrs = "-"
+	}
+	return "[" + fg + ":" + bg + ":" + attrs + "]"
+}
+
+// withAttr returns the attributes with the flag added or removed
+func withAttr(attrs string, flag string, on bool) string {
+	attrs = strings.Replace(attrs, flag, "", -1)
+	if on {
+		attrs += flag
+	}
+	return attrs
+}
+
+// extendedColor reads a 256 color or true color from the parameters of an SGR
+// sequence that follow a 38 or 48, and returns the color and how many of the
+// parameters it used.
+func extendedColor(params []int) (string, int) {
+	if len(params) >= 2 && params[0] == 5 {
+		if hex := tcell.Color(params[1]).Hex(); hex >= 0 {
+			return fmt.Sprintf("#%06x", hex), 2
+		}
+		return "", 2
+	}
+	if len(params) >= 4 && params[0] == 2 {
+		return fmt.Sprintf("#%02x%02x%02x", params[1]&0xff, params[2]&0xff, params[3]&0xff), 4
+	}
+	return "", len(params)
+}
+
+// applySGR changes the style according to the parameters of an SGR sequence
+func (ts textStyle) applySGR(params []int) textStyle {
+	if len(params) == 0 {
+		params = []int{0}
+	}
+	for i := 0; i < len(params); i++ {
+		param := params[i]
+		switch {
+		case param == 0:
+			ts = textStyle{}
+		case param == 1:
+			ts.attrs = withAttr(ts.attrs, "b", true)
+		case param == 2:
+			ts.attrs = withAttr(ts.attrs, "d", true)
+		case param == 4:
+			ts.attrs = withAttr(ts.attrs, "u", true)
+		case param == 5:
+			ts.attrs = withAttr(ts.attrs, "l", true)
+		case param == 7:
+			ts.attrs = withAttr(ts.attrs, "r", true)
+		case param == 22:
+			ts.attrs = withAttr(withAttr(ts.attrs, "b", false), "d", false)
+		case param == 24:
+			ts.attrs = withAttr(ts.attrs, "u", false)
+		case param == 25:
+			ts.attrs = withAttr(ts.attrs, "l", false)
+		case param == 27:
+			ts.attrs = withAttr(ts.attrs, "r", false)
+		case param >= 30 && param <= 37:
+			ts.fg = ansiColorNames[param-30]
+		case param >= 90 && param <= 97:
+			ts.fg = ansiColorNames[param-90+8]
+		case param >= 40 && param <= 47:
+			ts.bg = ansiColorNames[param-40]
+		case param >= 100 && param <= 107:
+			ts.bg = ansiColorNames[param-100+8]
+		case param == 39:
+			ts.fg = ""
+		case param == 49:
+			ts.bg = ""
+		case param == 38 || param == 48:
+			color, used := extendedColor(params[i+1:])
+			if param == 38 {
+				ts.fg = color
+			} else {
+				ts.bg = color
+			}
+			i += used
+		}
+	}
+	return ts
+}
+
+// parseSGRParams parses the parameters of an SGR sequence like "1;31"
+func parseSGRParams(paramText string) []int {
+	var params []int
+	if paramText == "" {
+		return params
+	}
+	for _, field := range strings.FieldsFunc(paramText, func(r rune) bool { return r == ';' || r == ':' }) {
+		param, err := strconv.Atoi(field)
+		if err != nil {
+			param = 0
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// controlPicture returns the visible symbol for a control character,
+// like ␛ for escape, from the Unicode control pictures block.
+func controlPicture(r rune) rune {
+	if r == 0x7f {
+		return '␡'
+	}
+	return 0x2400 + r
+}
+
+// escapeSequenceEnd returns the length of the CSI escape sequence at the
+// start of the text, which starts with an escape character, along with its
+// final byte and its parameters. The length is 1 if it is not a CSI sequence.
+func escapeSequenceEnd(text string) (int, byte, string) {
+	if len(text) < 2 || text[1] != '[' {
+		return 1, 0, ""
+	}
+	for i := 2; i < len(text); i++ {
+		if c := text[i]; c >= 0x40 && c <= 0x7e {
+			return i + 1, c, text[2:i]
+		}
+	}
+	return len(text), 0, ""
+}
+
+// renderLogLine turns a log line into text for a TextView with dynamic colors
+// and regions. Anything in the line that looks like a tview tag is escaped so
+// it shows as it is, ANSI color sequences become tview colors or are removed
+// according to ansiMode, other escape sequences are removed and control
+// characters are shown as visible symbols. The highlight rules are drawn over
+// the colors of the line, and if any rule has a gutter marker the line starts
+// with a gutter. A trailing line ending is kept as a single newline, and the
+// colors are reset at the end of the line so they do not run into the next
+// one.
+func renderLogLine(line []byte) string {
+	text := string(line)
+	lineEnd := ""
+	if strings.HasSuffix(text, "\n") {
+		text = strings.TrimRight(text, "\r\n")
+		lineEnd = "\n"
+	}
+
+	var rendered strings.Builder
+	overlay, gutter := highlightLine(text)
+	if hasGutter() {
+		rendered.WriteString(orDefault(gutter, " ") + " ")
+	}
+
+	var plain strings.Builder
+	var style textStyle
+	currentTag := style.tag()
+	flushPlain := func() {
+		rendered.WriteString(tview.Escape(plain.String()))
+		plain.Reset()
+	}
+	setStyle := func(i int) {
+		if overlay == nil && style.tag() == currentTag {
+			return
+		}
+		tag := style.tag()
+		if overlay != nil {
+			tag = style.over(overlay[i]).tag()
+		}
+		if tag != currentTag {
+			flushPlain()
+			rendered.WriteString(tag)
+			currentTag = tag
+		}
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r == 0x1b {
+			length, final, params := escapeSequenceEnd(text[i:])
+			if length > 1 {
+				if final == 'm' && ansiMode == ansiColors {
+					style = style.applySGR(parseSGRParams(params))
+				}
+				i += length
+				continue
+			}
+		}
+
+		setStyle(i)
+		switch {
+		case r == '\t':
+			plain.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			plain.WriteRune(controlPicture(r))
+		case r == utf8.RuneError && size == 1:
+			plain.WriteRune('�')
+		default:
+			plain.WriteString(text[i : i+size])
+		}
+		i += size
+	}
+	flushPlain()
+
+	if resetTag := (textStyle{}).tag(); currentTag != resetTag {
+		rendered.WriteString(resetTag)
+	}
+	return rendered.String() + lineEnd
+}
+
+// plainLineWidth returns how many columns the log line takes up on the
+// screen once it is rendered, not counting escape sequences and the line
+// ending.
+func plainLineWidth(line []byte) int {
+	var width int
+	if hasGutter() {
+		width = 2
+	}
+	text := strings.TrimRight(string(line), "\r\n")
+	for i := 0; i < len(text); {
+		if text[i] == 0x1b {
+			if length, _, _ := escapeSequenceEnd(text[i:]); length > 1 {
+				i += length
+				continue
+			}
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		width++
+		i += size
+	}
+	return width
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"fmt"
+)
+
+func Example_renderLogLine() {
+	lines := []string{
+		"I[2020-05-25|08:47:33.663] plain line\n",
+		"tags like [red] and [\"x\"] are shown as they are\n",
+		"\x1b[1;31mERROR\x1b[0m something failed\r\n",
+		"\x1b[38;5;208morange\x1b[39m and \x1b[38;2;1;2;3mtrue color",
+		"bell\x07 and escape\x1b and \x1b[2K erase",
+	}
+
+	for _, line := range lines {
+		fmt.Printf("%q\n", renderLogLine([]byte(line)))
+	}
+
+	ansiMode = ansiStrip
+	fmt.Printf("%q\n", renderLogLine([]byte(lines[2])))
+	ansiMode = ansiColors
+
+	// Output: "I[2020-05-25|08:47:33.663] plain line\n"
+	// "tags like [red[] and [\"x\"[] are shown as they are\n"
+	// "[maroon:black:b]ERROR[-:black:-] something failed\n"
+	// "[#ff8700:black:-]orange[-:black:-] and [#010203:black:-]true color[-:black:-]"
+	// "bell␇ and escape␛ and  erase"
+	// "ERROR something failed\n"
+}
+
+func Example_renderLogLine_highlight() {
+	for _, args := range [][]string{
+		{"module=consensus", "line", "fg=aqua"},
+		{"height=*", "bold"},
+		{"(?i)panic", "bg=red", "gutter=!"},
+	} {
+		rule, err := parseHighlightArgs(args)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		highlightRules = append(highlightRules, rule)
+	}
+
+	lines := []string{
+		"I[2020-05-25|08:47:33.663] Executed block module=state height=12\n",
+		"I[2020-05-25|08:47:33.700] Timed out module=consensus height=13\n",
+		"{\"height\":\"14\",\"msg\":\"PANIC: \x1b[33mstopping\x1b[0m\"}\n",
+	}
+	for _, line := range lines {
+		fmt.Printf("%q\n", renderLogLine([]byte(line)))
+	}
+	fmt.Println(describeHighlightRules())
+	highlightRules = nil
+
+	// Output: "  I[2020-05-25|08:47:33.663] Executed block module=state height=[-:black:b]12[-:black:-]\n"
+	// "  [aqua:black:-]I[2020-05-25|08:47:33.700] Timed out module=consensus height=[aqua:black:b]13[-:black:-]\n"
+	// "[yellow]![-] {\"height\":\"[-:black:b]14[-:black:-]\",\"msg\":\"[-:red:-]PANIC[-:black:-]: [olive:black:-]stopping[-:black:-]\"}\n"
+	// 1. module="consensus" line fg=aqua  2. height=* bold  3. "(?i)panic" bg=red gutter=!
+}
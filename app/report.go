@@ -0,0 +1,214 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// reportContextLines is how many lines before and after the time of an
+// annotation are shown from every file in a report
+const reportContextLines = 3
+
+// reportTimeFormat is how the time of an annotation is shown in a report
+const reportTimeFormat = "2006-01-02 15:04:05.000 MST"
+
+// reportLine is one log line shown in a report
+type reportLine struct {
+	text string // The log line without its line ending
+	at   bool   // Whether this is the annotated line, or the closest line at its time in another file
+}
+
+// reportSection is the context from one file at the time of an annotation
+type reportSection struct {
+	fileName string       // The name of the log file
+	lines    []reportLine // The lines around the time of the annotation
+}
+
+// contextLines returns the lines around the chunk, with the chunk itself marked
+func contextLines(chunk *filechunk.FileChunk) []reportLine {
+	var before []reportLine
+	prevChunk := chunk
+	for i := 0; i < reportContextLines; i++ {
+		if prevChunk = prevChunk.GetPrevFileChunk(); prevChunk == nil {
+			break
+		}
+		before = append(before, reportLine{text: string(bytes.TrimRight(prevChunk.FileChunkBytes, "\r\n"))})
+	}
+
+	var lines []reportLine
+	for i := len(before) - 1; i >= 0; i-- {
+		lines = append(lines, before[i])
+	}
+	lines = append(lines, reportLine{text: string(bytes.TrimRight(chunk.FileChunkBytes, "\r\n")), at: true})
+
+	nextChunk := chunk
+	for i := 0; i < reportContextLines; i++ {
+		if nextChunk = nextChunk.GetNextFileChunk(); nextChunk == nil {
+			break
+		}
+		lines = append(lines, reportLine{text: string(bytes.TrimRight(nextChunk.FileChunkBytes, "\r\n"))})
+	}
+	return lines
+}
+
+// annotationSections returns the context from every file at the time of the
+// annotation. The annotated file shows the lines around the annotated line,
+// and the other files show the lines around their closest line at that time.
+func annotationSections(fileViews []fileView, a *annotation) []reportSection {
+	var sections []reportSection
+	names := uniqueNames(logFilenames(fileViews))
+	for i := range fileViews {
+		var chunk *filechunk.FileChunk
+		if i == a.File {
+			chunk = fileViews[i].currChunk.GetFileChunkAtOffset(a.Offset)
+		} else if a.Time > 1 {
+			chunk = fileViews[i].chunkClosestToTime(a.Time)
+		}
+		if chunk == nil {
+			continue
+		}
+		sections = append(sections, reportSection{
+			fileName: names[i],
+			lines:    contextLines(chunk),
+		})
+	}
+	return sections
+}
+
+// codeFence returns a fence for a Markdown code block of the lines, made of
+// more backticks than the longest run of backticks in any of them so that
+// no line can close the block early
+func codeFence(lines []reportLine) string {
+	longest := 0
+	for _, line := range lines {
+		run := 0
+		for _, c := range line.text {
+			if c != '`' {
+				run = 0
+				continue
+			}
+			if run++; run > longest {
+				longest = run
+			}
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// writeMarkdownReport writes the report as Markdown, with the lines of each
+// file in a code block and the annotated lines marked with ">".
+func writeMarkdownReport(w io.Writer, fileViews []fileView, annotations []*annotation, notes []string) {
+	fmt.Fprintf(w, "# Incident report\n\n")
+	fmt.Fprintf(w, "Log files:\n\n")
+	for i := range fileViews {
+		fmt.Fprintf(w, "- %s\n", fileViews[i].logFilename)
+	}
+
+	if len(notes) > 0 {
+		fmt.Fprintf(w, "\n## Notes\n\n")
+		for _, note := range notes {
+			fmt.Fprintf(w, "- %s\n", note)
+		}
+	}
+
+	fmt.Fprintf(w, "\n## Timeline\n")
+	for _, a := range annotations {
+		fmt.Fprintf(w, "\n### %s: %s\n\n", time.Unix(0, a.Time).UTC().Format(reportTimeFormat), a.Text)
+		if a.File < len(fileViews) {
+			fmt.Fprintf(w, "On %s:\n\n", uniqueNames(logFilenames(fileViews))[a.File])
+		}
+		fmt.Fprintf(w, "    %s\n", a.Line)
+		for _, section := range annotationSections(fileViews, a) {
+			fence := codeFence(section.lines)
+			fmt.Fprintf(w, "\n#### %s\n\n%s\n", section.fileName, fence)
+			for _, line := range section.lines {
+				prefix := "  "
+				if line.at {
+					prefix = "> "
+				}
+				fmt.Fprintf(w, "%s%s\n", prefix, line.text)
+			}
+			fmt.Fprintf(w, "%s\n", fence)
+		}
+	}
+}
+
+// writeHTMLReport writes the report as a single HTML page, with the lines
+// of each file in a pre block and the annotated lines highlighted.
+func writeHTMLReport(w io.Writer, fileViews []fileView, annotations []*annotation, notes []string) {
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Incident report</title>\n")
+	fmt.Fprintf(w, "<style>pre { background: #f4f4f4; padding: 0.5em; overflow-x: auto; } mark { display: block; }</style>\n")
+	fmt.Fprintf(w, "</head>\n<body>\n<h1>Incident report</h1>\n<p>Log files:</p>\n<ul>\n")
+	for i := range fileViews {
+		fmt.Fprintf(w, "<li>%s</li>\n", html.EscapeString(fileViews[i].logFilename))
+	}
+	fmt.Fprintf(w, "</ul>\n")
+
+	if len(notes) > 0 {
+		fmt.Fprintf(w, "<h2>Notes</h2>\n<ul>\n")
+		for _, note := range notes {
+			fmt.Fprintf(w, "<li>%s</li>\n", html.EscapeString(note))
+		}
+		fmt.Fprintf(w, "</ul>\n")
+	}
+
+	fmt.Fprintf(w, "<h2>Timeline</h2>\n")
+	for _, a := range annotations {
+		fmt.Fprintf(w, "<h3>%s: %s</h3>\n", time.Unix(0, a.Time).UTC().Format(reportTimeFormat), html.EscapeString(a.Text))
+		if a.File < len(fileViews) {
+			fmt.Fprintf(w, "<p>On %s:</p>\n", html.EscapeString(uniqueNames(logFilenames(fileViews))[a.File]))
+		}
+		fmt.Fprintf(w, "<pre>%s</pre>\n", html.EscapeString(a.Line))
+		for _, section := range annotationSections(fileViews, a) {
+			fmt.Fprintf(w, "<h4>%s</h4>\n<pre>", html.EscapeString(section.fileName))
+			for _, line := range section.lines {
+				if line.at {
+					fmt.Fprintf(w, "<mark>%s</mark>", html.EscapeString(line.text))
+				} else {
+					fmt.Fprintf(w, "%s\n", html.EscapeString(line.text))
+				}
+			}
+			fmt.Fprintf(w, "</pre>\n")
+		}
+	}
+	fmt.Fprintf(w, "</body>\n</html>\n")
+}
+
+// exportReport writes a report of the annotations in time order to the file.
+// The report is HTML if the file name ends in .html or .htm and Markdown
+// otherwise. The report is buffered, and the first error writing it is
+// returned when the buffer is flushed.
+func exportReport(filename string, fileViews []fileView, annotations []*annotation, notes []string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	sorted := append([]*annotation(nil), annotations...)
+	sortAnnotations(sorted)
+
+	w := bufio.NewWriter(file)
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".html", ".htm":
+		writeHTMLReport(w, fileViews, sorted, notes)
+	default:
+		writeMarkdownReport(w, fileViews, sorted, notes)
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+func Example_writeReport() {
+	fileViews := openLogFileViews(
+		"I[2020-05-25|08:45:30.000] start\n"+
+			"E[2020-05-25|08:45:31.000] bad input ```x```\n"+
+			"I[2020-05-25|08:45:32.000] stop\n",
+		"I[2020-05-25|08:45:30.500] start\n"+
+			"I[2020-05-25|08:45:31.500] <retry>\n",
+	)
+	dir := filepath.Dir(fileViews[0].logFilename)
+	fileViews[0].currChunk = fileViews[0].currChunk.GetNextFileChunk()
+	annotations := []*annotation{newAnnotation(fileViews, 0, "input rejected")}
+	notes := []string{"seen after the upgrade"}
+
+	var markdown, page bytes.Buffer
+	writeMarkdownReport(&markdown, fileViews, annotations, notes)
+	writeHTMLReport(&page, fileViews, annotations, notes)
+	fmt.Print(strings.ReplaceAll(markdown.String(), dir, "logs"))
+	fmt.Print(strings.ReplaceAll(page.String(), dir, "logs"))
+
+	// Output: # Incident report
+	//
+	// Log files:
+	//
+	// - logs/node0.log
+	// - logs/node1.log
+	//
+	// ## Notes
+	//
+	// - seen after the upgrade
+	//
+	// ## Timeline
+	//
+	// ### 2020-05-25 08:45:31.000 UTC: input rejected
+	//
+	// On node0.log:
+	//
+	//     E[2020-05-25|08:45:31.000] bad input ```x```
+	//
+	// #### node0.log
+	//
+	// ````
+	//   I[2020-05-25|08:45:30.000] start
+	// > E[2020-05-25|08:45:31.000] bad input ```x```
+	//   I[2020-05-25|08:45:32.000] stop
+	// ````
+	//
+	// #### node1.log
+	//
+	// ```
+	// > I[2020-05-25|08:45:30.500] start
+	//   I[2020-05-25|08:45:31.500] <retry>
+	// ```
+	// <!DOCTYPE html>
+	// <html>
+	// <head>
+	// <meta charset="utf-8">
+	// <title>Incident report</title>
+	// <style>pre { background: #f4f4f4; padding: 0.5em; overflow-x: auto; } mark { display: block; }</style>
+	// </head>
+	// <body>
+	// <h1>Incident report</h1>
+	// <p>Log files:</p>
+	// <ul>
+	// <li>logs/node0.log</li>
+	// <li>logs/node1.log</li>
+	// </ul>
+	// <h2>Notes</h2>
+	// <ul>
+	// <li>seen after the upgrade</li>
+	// </ul>
+	// <h2>Timeline</h2>
+	// <h3>2020-05-25 08:45:31.000 UTC: input rejected</h3>
+	// <p>On node0.log:</p>
+	// <pre>E[2020-05-25|08:45:31.000] bad input ```x```</pre>
+	// <h4>node0.log</h4>
+	// <pre>I[2020-05-25|08:45:30.000] start
+	// <mark>E[2020-05-25|08:45:31.000] bad input ```x```</mark>I[2020-05-25|08:45:32.000] stop
+	// </pre>
+	// <h4>node1.log</h4>
+	// <pre><mark>I[2020-05-25|08:45:30.500] start</mark>I[2020-05-25|08:45:31.500] &lt;retry&gt;
+	// </pre>
+	// </body>
+	// </html>
+}
@@ -0,0 +1,195 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// defaultRestartPattern matches the first line that a tendermint node logs
+// when it starts
+const defaultRestartPattern = "Starting multiAppConn service"
+
+// restartRegression is how far back the time of a line has to go from the
+// line before it for the line to start a new run of the node, so that
+// lines that are logged a little out of order do not count
+const restartRegression = time.Second
+
+// compileRestartPattern compiles the pattern of the line that a node logs
+// when it starts, or returns the default pattern if it is empty. Every
+// match after the first one in a run starts a new run of the node.
+func compileRestartPattern(text string) (*regexp.Regexp, error) {
+	if text == "" {
+		text = defaultRestartPattern
+	}
+	pattern, err := regexp.Compile(text)
+	if err != nil {
+		return nil, fmt.Errorf("bad restart pattern: %v", err)
+	}
+	return pattern, nil
+}
+
+// restart is the start of a new run of a node in the middle of its log
+// file, when the node was restarted and kept logging to the same file
+type restart struct {
+	file   int    // The index of the file
+	offset int64  // The offset of the first line of the run
+	time   int64  // The time of the first line of the run, or of the last line before it with a time
+	reason string // Why the line starts a run
+}
+
+// scanRestarts reads the whole file and finds where the runs of the node
+// start. A run starts at a line that matches the pattern, unless the run
+// has not had one yet, and at a line whose time goes back by more than
+// restartRegression, since a restarted node can have a clock that is
+// behind or a log that was appended out of order.
+func scanRestarts(file io.ReaderAt, index int, pattern *regexp.Regexp) ([]*restart, error) {
+	var restarts []*restart
+	reader := bufio.NewReader(io.NewSectionReader(file, 0, math.MaxInt64))
+	var offset, lastTime int64
+	runHasStart := false
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			lineTime := filechunk.GetTimeStampFromLine(string(line))
+			switch {
+			case lineTime > 1 && lastTime > 1 && lineTime < lastTime-int64(restartRegression):
+				restarts = append(restarts, &restart{file: index, offset: offset, time: lineTime,
+					reason: "time went back " + time.Duration(lastTime-lineTime).String()})
+				runHasStart = pattern.Match(line)
+			case pattern.Match(line):
+				if runHasStart {
+					startTime := lineTime
+					if startTime <= 1 {
+						startTime = lastTime
+					}
+					restarts = append(restarts, &restart{file: index, offset: offset, time: startTime, reason: "start line"})
+				}
+				runHasStart = true
+			}
+			if lineTime > 1 {
+				lastTime = lineTime
+			}
+		}
+		offset += int64(len(line))
+		if err == io.EOF {
+			return restarts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// runNumber returns the number of the run of the file that the offset is
+// in, counting from 1, given the offsets where its runs start
+func runNumber(restarts []*restart, offset int64) int {
+	return 1 + sort.Search(len(restarts), func(i int) bool { return restarts[i].offset > offset })
+}
+
+// allRestarts returns the restarts of all the files in time order. The
+// restarts of each file stay in the order they are in the file, since one
+// found by the time going back is earlier than the restarts before it,
+// so the files are merged like stepping merges their lines: the file
+// whose next restart is the earliest goes first.
+func allRestarts(fileViews []fileView) []*restart {
+	var restarts []*restart
+	next := make([]int, len(fileViews))
+	for {
+		earliest := -1
+		for i := range fileViews {
+			if next[i] == len(fileViews[i].restarts) {
+				continue
+			}
+			if earliest < 0 || fileViews[i].restarts[next[i]].time < fileViews[earliest].restarts[next[earliest]].time {
+				earliest = i
+			}
+		}
+		if earliest < 0 {
+			return restarts
+		}
+		restarts = append(restarts, fileViews[earliest].restarts[next[earliest]])
+		next[earliest]++
+	}
+}
+
+// adjacentRestart returns the restart after the current position of the
+// files, or before it if forward is false, or nil if there is none. When
+// the file that moved last is at one of the restarts, the one next to it
+// in the list is returned, so that a restart whose time went back is not
+// skipped or reached again. Otherwise the restarts are compared with the
+// time of that file's line, and with the current lines of their own files
+// when they are at the same time.
+func adjacentRestart(fileViews []fileView, restarts []*restart, forward bool) *restart {
+	active := activeFileIndex(fileViews)
+	chunk := fileViews[active].currChunk
+	for i, r := range restarts {
+		if r.file == active && r.offset == chunk.FileOffsetStart {
+			if forward && i+1 < len(restarts) {
+				return restarts[i+1]
+			} else if !forward && i > 0 {
+				return restarts[i-1]
+			}
+			return nil
+		}
+	}
+
+	currTime := lineTime(chunk)
+	if forward {
+		for _, r := range restarts {
+			if r.time > currTime || r.time == currTime && r.offset > fileViews[r.file].currChunk.FileOffsetStart {
+				return r
+			}
+		}
+		return nil
+	}
+	for i := len(restarts) - 1; i >= 0; i-- {
+		r := restarts[i]
+		if r.time < currTime || r.time == currTime && r.offset < fileViews[r.file].currChunk.FileOffsetStart {
+			return r
+		}
+	}
+	return nil
+}
+
+// describeRestarts lists the restarts of all the files in time order,
+// numbered so that they can be jumped to, with how many runs each file has
+// and the pattern they were found by
+func describeRestarts(fileViews []fileView, pattern *regexp.Regexp, restarts []*restart) string {
+	var report strings.Builder
+	fmt.Fprintf(&report, "restarts found by %s or time going back more than %s\n\n", pattern, restartRegression)
+	names := uniqueNames(logFilenames(fileViews))
+	nameWidth := longestName(names)
+	for i := range fileViews {
+		fmt.Fprintf(&report, "%-*s %s\n", nameWidth, names[i], countOf(len(fileViews[i].restarts)+1, "run"))
+	}
+	if len(restarts) == 0 {
+		fmt.Fprintf(&report, "\nno file restarts\n")
+		return report.String()
+	}
+	fmt.Fprintf(&report, "\n")
+	for i, r := range restarts {
+		fv := &fileViews[r.file]
+		fmt.Fprintf(&report, "%4d. %-*s %s  run %d  %s\n", i+1, nameWidth, names[r.file],
+			time.Unix(0, r.time).UTC().Format(markTimeFormat), runNumber(fv.restarts, r.offset), r.reason)
+	}
+	return report.String()
+}
+
+// parseRestartNumber reads the number of a restart as it is listed by the
+// restarts command
+func parseRestartNumber(arg string, restarts []*restart) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(restarts) {
+		return -1, fmt.Errorf("no restart %s, type restarts to list them", arg)
+	}
+	return n - 1, nil
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+func Example_scanRestarts() {
+	log := "I[2020-05-25|08:45:31.749] Starting multiAppConn service\n" +
+		"I[2020-05-25|08:45:33.239] Committed state height=1\n" +
+		"I[2020-05-25|08:45:34.100] Starting multiAppConn service\n" +
+		"I[2020-05-25|08:45:35.239] Committed state height=1\n" +
+		"I[2020-05-25|08:45:30.000] Version info\n" +
+		"I[2020-05-25|08:45:30.100] Starting multiAppConn service\n" +
+		"I[2020-05-25|08:45:30.050] Committed state height=1\n"
+	restarts, _ := scanRestarts(strings.NewReader(log), 0, regexp.MustCompile(defaultRestartPattern))
+	for _, r := range restarts {
+		fmt.Println(r.offset, runNumber(restarts, r.offset), r.reason)
+	}
+	fmt.Println(runNumber(restarts, 0), runNumber(restarts, int64(len(log))))
+
+	// Output: 109 2 start line
+	// 218 3 time went back 5.239s
+	// 1 3
+}
+
+func Example_adjacentRestart() {
+	fileViews := openLogFileViews(
+		"I[2020-05-25|08:45:30.000] Starting multiAppConn service\n"+
+			"I[2020-05-25|08:45:31.000] Committed state height=1\n"+
+			"I[2020-05-25|08:45:34.000] Starting multiAppConn service\n"+
+			"I[2020-05-25|08:45:35.000] Committed state height=1\n"+
+			"I[2020-05-25|08:45:32.000] Version info\n"+
+			"I[2020-05-25|08:45:33.000] Committed state height=1\n",
+		"I[2020-05-25|08:45:30.500] Starting multiAppConn service\n"+
+			"I[2020-05-25|08:45:33.000] Starting multiAppConn service\n",
+	)
+	pattern, _ := compileRestartPattern("")
+	for i := range fileViews {
+		fileViews[i].restarts, _ = scanRestarts(fileViews[i].file, i, pattern)
+	}
+	restarts := allRestarts(fileViews)
+
+	step := func(forward bool) {
+		r := adjacentRestart(fileViews, restarts, forward)
+		if r == nil {
+			fmt.Println("no more restarts")
+			return
+		}
+		SyncAllToFileChunk(fileViews, r.file, fileViews[r.file].currChunk.GetFileChunkAtOffset(r.offset))
+		fmt.Println(r.file, r.offset, r.reason)
+	}
+	for i := 0; i < 4; i++ {
+		step(true)
+	}
+	for i := 0; i < 3; i++ {
+		step(false)
+	}
+
+	_, err := compileRestartPattern("(")
+	fmt.Println(err)
+
+	// Output: 1 57 start line
+	// 0 109 start line
+	// 0 218 time went back 3s
+	// no more restarts
+	// 0 109 start line
+	// 1 57 start line
+	// no more restarts
+	// bad restart pattern: error parsing regexp: missing closing ): `(`
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"bytes"
+)
+
+// lastSearch is the text of the last search, which is repeated
+// by the search-next and search-prev key actions
+var lastSearch string
+
+// SearchAll looks for the next log line in any of the files that contains
+// the text, going through the files in merged time order from the current
+// position. If a line is found, that file is moved to it and all the other
+// files are synced to its time. It returns whether a line was found.
+func SearchAll(fileViews []fileView, text string, forward bool) bool {
+	if text == "" {
+		return false
+	}
+
+	step := stepMergeForward
+	if !forward {
+		step = stepMergeBackward
+	}
+
+	searchBytes := []byte(text)
+	cursors := currentChunks(fileViews)
+	for {
+		index, chunk := step(cursors)
+		if index < 0 {
+			return false
+		}
+		if bytes.Contains(chunk.FileChunkBytes, searchBytes) {
+			SyncAllToFileChunk(fileViews, index, chunk)
+			return true
+		}
+	}
+}
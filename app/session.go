@@ -0,0 +1,201 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// sessionVersion is the version of the session file format that is written
+const sessionVersion = 1
+
+// sessionFile is one of the log files of a session
+type sessionFile struct {
+	Path     string    `json:"path"`     // The path of the log file, relative to the session file when it can be
+	Size     int64     `json:"size"`     // The size of the file when the session was saved
+	ModTime  time.Time `json:"mod_time"` // When the file was last changed before the session was saved
+	Position int64     `json:"position"` // The offset of the current line of the file
+}
+
+// Session is everything about an investigation that is saved in a session
+// file so it can be picked up again later with logsync --session, or by a
+// teammate. The paths of the log files are saved relative to the session
+// file so the session can be shared along with the logs.
+type Session struct {
+	Version        int               `json:"version"`
+	Files          []sessionFile     `json:"files"`
+	Layout         *paneLayout       `json:"layout"`
+	View           string            `json:"view"`
+	Quantum        string            `json:"quantum"`
+	Bucket         string            `json:"bucket"`
+	ContextPercent int               `json:"context_percent"`
+	ANSI           string            `json:"ansi,omitempty"`
+	LastSearch     string            `json:"last_search,omitempty"`
+	Marks          []*mark           `json:"marks,omitempty"`
+	Aliases        map[string]string `json:"aliases,omitempty"`
+	Notes          []string          `json:"notes,omitempty"`
+	Annotations    []*annotation     `json:"annotations,omitempty"`
+	Watches        []*watch          `json:"watches,omitempty"`
+	Highlights     []*highlightRule  `json:"highlights"`
+	ClockOffsets   map[string]string `json:"clock_offsets"`
+	FollowID       string            `json:"follow_id,omitempty"`
+
+	filename string   // The session file, which save writes to when no file is given
+	changed  []string // The log files that changed since the session was saved
+}
+
+// LoadSession reads a session file. It returns the session and the paths
+// of its log files, which are resolved relative to the session file.
+func LoadSession(filename string) (*Session, []string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	session := &Session{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, nil, fmt.Errorf("bad session file %s: %v", filename, err)
+	}
+	if session.Version > sessionVersion {
+		return nil, nil, fmt.Errorf("session file %s is version %d, but this logsync only reads up to version %d", filename, session.Version, sessionVersion)
+	}
+	if len(session.Files) == 0 {
+		return nil, nil, fmt.Errorf("session file %s has no log files", filename)
+	}
+	session.filename = filename
+
+	var logFilenames []string
+	for _, file := range session.Files {
+		path := file.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(filename), path)
+		}
+		logFilenames = append(logFilenames, path)
+
+		if fileInfo, err := os.Stat(path); err == nil {
+			if fileInfo.Size() != file.Size || !fileInfo.ModTime().Equal(file.ModTime) {
+				session.changed = append(session.changed, file.Path)
+			}
+		}
+	}
+	return session, logFilenames, nil
+}
+
+// sessionPath returns the path of the log file to save in the session file,
+// which is relative to the directory of the session file if it can be.
+func sessionPath(sessionFilename string, logFilename string) string {
+	absSession, err := filepath.Abs(sessionFilename)
+	if err != nil {
+		return logFilename
+	}
+	absLog, err := filepath.Abs(logFilename)
+	if err != nil {
+		return logFilename
+	}
+	relPath, err := filepath.Rel(filepath.Dir(absSession), absLog)
+	if err != nil {
+		return absLog
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// save writes the session to the file, with the positions of the files
+// taken from the fileViews.
+func (s *Session) save(filename string, fileViews []fileView) error {
+	s.Version = sessionVersion
+	s.Files = nil
+	for i := range fileViews {
+		fv := &fileViews[i]
+		file := sessionFile{
+			Path:     sessionPath(filename, fv.logFilename),
+			Position: fv.currChunk.FileOffsetStart,
+		}
+		if fileInfo, err := fv.file.Stat(); err == nil {
+			file.Size = fileInfo.Size()
+			file.ModTime = fileInfo.ModTime()
+		}
+		s.Files = append(s.Files, file)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filename, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	s.filename = filename
+	return nil
+}
+
+// restorePositions moves every file to the line it was on when the session
+// was saved. The marks are found in the files when they are first jumped to.
+func (s *Session) restorePositions(fileViews []fileView) {
+	for i := range fileViews {
+		if i < len(s.Files) {
+			fileViews[i].currChunk = fileViews[i].currChunk.GetFileChunkAtOffset(s.Files[i].Position)
+		}
+		fileViews[i].SetDisplayText()
+	}
+}
+
+// restoreSession puts back the settings, marks and positions of the session.
+// The highlight rules and clock offsets of the session replace the ones from
+// the config, unless the session was saved without them. It returns the
+// problems with the settings that could not be put back.
+func restoreSession(session *Session, fileViews []fileView, layout *paneLayout, marks *markList, commands *commandRegistry, watches *watchList, clockOffsets []time.Duration) []error {
+	var errs []error
+	if session.Layout != nil && len(session.Layout.Hidden) == len(fileViews) && len(session.Layout.Weights) == len(fileViews) {
+		*layout = *session.Layout
+	}
+	if quantum, err := time.ParseDuration(session.Quantum); err == nil && quantum > 0 {
+		timeQuantum = quantum
+	}
+	if size, err := time.ParseDuration(session.Bucket); err == nil && size > 0 {
+		bucketSize = size
+	}
+	if session.ContextPercent >= 0 && session.ContextPercent <= 100 {
+		contextAbovePercent = session.ContextPercent
+	}
+	if session.ANSI == ansiColors || session.ANSI == ansiStrip {
+		ansiMode = session.ANSI
+	}
+	lastSearch = session.LastSearch
+	marks.marks = session.Marks
+	var aliasNames []string
+	for name := range session.Aliases {
+		aliasNames = append(aliasNames, name)
+	}
+	sort.Strings(aliasNames)
+	for _, name := range aliasNames {
+		if err := commands.setAlias(name, session.Aliases[name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, w := range session.Watches {
+		if err := watches.add(w); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if session.Highlights != nil {
+		highlightRules = nil
+		for _, rule := range session.Highlights {
+			if err := rule.compile(); err != nil {
+				errs = append(errs, err)
+			} else {
+				highlightRules = append(highlightRules, rule)
+			}
+		}
+	}
+	if session.ClockOffsets != nil {
+		offsets, offsetErrs := parseClockOffsets(fileViews, session.ClockOffsets)
+		copy(clockOffsets, offsets)
+		errs = append(errs, offsetErrs...)
+	}
+	setFollowID(session.FollowID)
+	session.restorePositions(fileViews)
+	return errs
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+)
+
+func Example_restoreSession() {
+	fileViews := openFileViews("node0-json.log", "node1-json.log")
+	rule, _ := parseHighlightArgs([]string{"height=*", "fg=auto", "gutter=>"})
+	highlightRules = []*highlightRule{rule}
+	setFollowID("8f698d97563b")
+	clockOffsets := []time.Duration{0, -150 * time.Millisecond}
+
+	sessionFile, err := ioutil.TempFile("", "logsync-session")
+	if err != nil {
+		log.Fatal(err)
+	}
+	sessionFile.Close()
+	defer os.Remove(sessionFile.Name())
+	session := &Session{
+		ContextPercent: contextAbovePercent,
+		Highlights:     append([]*highlightRule{}, highlightRules...),
+		ClockOffsets:   clockOffsetConfig(fileViews, clockOffsets),
+		FollowID:       followID,
+		Aliases:        map[string]string{"start": "mark start", "bad name": "mark"},
+		Watches:        []*watch{{Name: "height"}, {Name: "round", Match: "Round("}},
+	}
+	if err := session.save(sessionFile.Name(), fileViews); err != nil {
+		log.Fatal(err)
+	}
+
+	highlightRules = nil
+	setFollowID("")
+	session, _, err = LoadSession(sessionFile.Name())
+	if err != nil {
+		log.Fatal(err)
+	}
+	restoredOffsets := make([]time.Duration, len(fileViews))
+	errs := restoreSession(session, fileViews, newPaneLayout(len(fileViews)), newMarkList(), newCommandRegistry(), &watchList{}, restoredOffsets)
+	fmt.Println(describeHighlightRules())
+	fmt.Println(describeClockOffsets(fileViews, restoredOffsets))
+	fmt.Println("following", followID)
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+	highlightRules = nil
+	setFollowID("")
+
+	// Output: 1. height=* fg=auto gutter=>
+	// clock offsets: node1-json.log -150ms
+	// following 8f698d97563b
+	// bad alias name "bad name"
+	// bad match for watch round: error parsing regexp: missing closing ): `Round(`
+}
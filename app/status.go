@@ -0,0 +1,172 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// clusterTimeFormat is how the time of the cluster is shown in the status bar
+const clusterTimeFormat = "2006-01-02 15:04:05.000 MST"
+
+// paneTimeFormat is how the time of the current line is shown in a pane title
+const paneTimeFormat = "15:04:05.000"
+
+// maxSyncLineCount is the most bytes of the file that are read to count
+// lines while the title is drawn. Counting more than that, like after a
+// jump far away, is done in the background.
+const maxSyncLineCount int64 = 1 << 20
+
+// lineNumber returns the line number, starting at 1, of the current chunk,
+// or 0 while the lines before it are being counted in the background.
+// The number of lines before the last offset we counted to is cached,
+// so that stepping only needs to count the lines in between.
+func (fv *fileView) lineNumber() int64 {
+	offset := fv.currChunk.FileOffsetStart
+	start, end, base, sign := fv.lineCountOffset, offset, fv.lineCountValue, int64(1)
+	if offset < fv.lineCountOffset/2 {
+		start, base = 0, 0
+	} else if offset < fv.lineCountOffset {
+		start, end, sign = offset, fv.lineCountOffset, -1
+	}
+
+	if end-start <= maxSyncLineCount || fv.queueUpdateDraw == nil {
+		fv.lineCountValue = base + sign*filechunk.CountLines(fv.file, start, end)
+		fv.lineCountOffset = offset
+		return fv.lineCountValue + 1
+	}
+
+	// The title is drawn again when the count is done
+	if !fv.lineCounting {
+		fv.lineCounting = true
+		go func() {
+			count := filechunk.CountLines(fv.file, start, end)
+			fv.queueUpdateDraw(func() {
+				fv.lineCountValue = base + sign*count
+				fv.lineCountOffset = offset
+				fv.lineCounting = false
+			})
+		}()
+	}
+	return 0
+}
+
+// statusTitle returns the title of the pane, which is the pane number, used
+// by the layout commands, and the file name followed
+// by the time of the current line, how far it is behind the leading file,
+// the line number, how far through the file it is, and which run of the
+// node it is in if the file has restarts.
+func (fv *fileView) statusTitle() string {
+	title := fmt.Sprintf("#%d %s", fv.index+1, tview.Escape(fv.logFilename))
+	if fv.currChunk == nil {
+		return title
+	}
+
+	if lineTime := fv.currChunk.LineTimeStamp; lineTime > 1 {
+		title += " | " + time.Unix(0, lineTime).UTC().Format(paneTimeFormat)
+		leadTime := CurrentClusterTime(fv.allFileViews)
+		if lineTime == leadTime {
+			title += " lead"
+		} else {
+			title += " " + time.Duration(lineTime-leadTime).String()
+		}
+	}
+
+	var percent float64 = 100
+	if fv.fileSize > 0 {
+		percent = 100 * float64(fv.currChunk.FileOffsetEnd+1) / float64(fv.fileSize)
+	}
+	if line := fv.lineNumber(); line > 0 {
+		title += fmt.Sprintf(" | line %d | %.1f%%", line, percent)
+	} else {
+		title += fmt.Sprintf(" | line ? | %.1f%%", percent)
+	}
+	if len(fv.restarts) > 0 {
+		title += fmt.Sprintf(" | run %d/%d", runNumber(fv.restarts, fv.currChunk.FileOffsetStart), len(fv.restarts)+1)
+	}
+
+	return title
+}
+
+// statusBar is the line below the file views that shows the time of the
+// cluster as a whole and which file was stepped last.
+type statusBar struct {
+	*tview.TextView
+	fileViews []fileView
+}
+
+// newStatusBar creates the status bar for all the fileViews
+func newStatusBar(fileViews []fileView) *statusBar {
+	return &statusBar{
+		TextView:  tview.NewTextView().SetDynamicColors(true),
+		fileViews: fileViews,
+	}
+}
+
+// markMoved records that the fileView at the index was stepped last. The
+// lastScrollTime of the fileViews only has a resolution of a second, so it
+// cannot tell which was last.
+func markMoved(fileViews []fileView, index int) {
+	var lastOrder int64
+	for i := range fileViews {
+		if fileViews[i].moveOrder > lastOrder {
+			lastOrder = fileViews[i].moveOrder
+		}
+	}
+	fileViews[index].moveOrder = lastOrder + 1
+}
+
+// lastMovedIndex returns the index of the fileView that was stepped
+// last, or -1 if none of them has been stepped yet.
+func lastMovedIndex(fileViews []fileView) int {
+	lastMoved := -1
+	var lastOrder int64
+	for i := range fileViews {
+		if fileViews[i].moveOrder > lastOrder {
+			lastOrder = fileViews[i].moveOrder
+			lastMoved = i
+		}
+	}
+	return lastMoved
+}
+
+// activeFileIndex returns the index of the fileView whose current line is
+// the one being looked at: the one stepped last, or the one furthest ahead
+// in time if none has been stepped.
+func activeFileIndex(fileViews []fileView) int {
+	if index := lastMovedIndex(fileViews); index > -1 {
+		return index
+	}
+	clusterTime := CurrentClusterTime(fileViews)
+	for i := range fileViews {
+		if fileViews[i].currChunk.LineTimeStamp == clusterTime {
+			return i
+		}
+	}
+	return 0
+}
+
+// Draw updates the status text from the current state of the
+// fileViews and then draws the underlying TextView.
+func (sb *statusBar) Draw(screen tcell.Screen) {
+	var status string
+	if clusterTime := CurrentClusterTime(sb.fileViews); clusterTime > 1 {
+		status = "[" + currentTheme.Accent + "]" + time.Unix(0, clusterTime).UTC().Format(clusterTimeFormat) + "[-]"
+	} else {
+		status = "[" + currentTheme.Accent + "]no timestamp[-]"
+	}
+
+	if lastMoved := lastMovedIndex(sb.fileViews); lastMoved > -1 {
+		status += " | last moved: " + tview.Escape(sb.fileViews[lastMoved].logFilename)
+	}
+	if followID != "" {
+		status += " | following " + tview.Escape(followID)
+	}
+
+	sb.SetText(status)
+	sb.TextView.Draw(screen)
+}
@@ -0,0 +1,256 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// maxTemplates is the most templates that are kept for each file, so that
+// lines that the masking does not make alike cannot use up all the memory
+const maxTemplates = 50000
+
+// maxTemplatesListed is the most templates that are listed at once
+const maxTemplatesListed = 500
+
+// maxTemplateHits is the most lines of each template that are kept for each
+// file to jump to
+const maxTemplateHits = 1000
+
+// templateMasks replace the parts of a log line that change from one line
+// to the next with placeholders, in order, so that lines that only differ
+// in those parts have the same template
+var templateMasks = []struct {
+	pattern     *regexp.Regexp
+	placeholder string
+}{
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T| ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?Z?`), "<time>"},
+	{regexp.MustCompile(`(=\s*)(?:\\?"(?:\\\\\\"|[^"\\]|\\[^"])*\\?"|'[^']*')`), "${1}<str>"},
+	{regexp.MustCompile(`\bBA\{\d+:[x_]*\}`), "BA{<bits>}"},
+	{regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`), "<ip>"},
+	{regexp.MustCompile(`\b(?:0x)?[0-9A-Fa-f]*\d[0-9A-Fa-f]*(?:-[0-9A-Fa-f]+)*\b`), "<hex>"},
+	{regexp.MustCompile(`\b\d+(?:\.\d+)?`), "<n>"},
+}
+
+// lineTemplate returns the template of the log line: the line with its
+// times, quoted values, tendermint bit arrays, IP addresses, hashes and
+// numbers masked and its runs of spaces collapsed
+func lineTemplate(line []byte) string {
+	for _, mask := range templateMasks {
+		if mask.placeholder == "<hex>" {
+			line = mask.pattern.ReplaceAllFunc(line, func(hex []byte) []byte {
+				if len(hex) < 8 {
+					return hex
+				}
+				return []byte("<hex>")
+			})
+			continue
+		}
+		line = mask.pattern.ReplaceAll(line, []byte(mask.placeholder))
+	}
+	return strings.Join(strings.Fields(string(line)), " ")
+}
+
+// templateHit is one line of a template
+type templateHit struct {
+	file   int   // The index of the file
+	offset int64 // The offset of the line
+	time   int64 // The time of the line, or of the last line before it with a time
+}
+
+// logTemplate is a message template and how often each file has it
+type logTemplate struct {
+	text   string        // The template
+	counts []int         // The number of lines of every file with the template
+	total  int           // The number of lines of all the files with the template
+	hits   []templateHit // The first maxTemplateHits lines of each file with the template, in time order
+}
+
+// files returns the number of files that have the template
+func (t *logTemplate) files() int {
+	files := 0
+	for _, count := range t.counts {
+		if count > 0 {
+			files++
+		}
+	}
+	return files
+}
+
+// scanTemplates reads the whole file and counts the lines of every template
+// in it. It returns the templates and whether there were too many of them
+// to keep them all.
+func scanTemplates(file io.ReaderAt, index int, numFiles int) (map[string]*logTemplate, bool, error) {
+	templates := make(map[string]*logTemplate)
+	full := false
+	reader := bufio.NewReader(io.NewSectionReader(file, 0, math.MaxInt64))
+	var offset, lastTime int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if lineTime := filechunk.GetTimeStampFromLine(string(line)); lineTime > 1 {
+				lastTime = lineTime
+			}
+			text := lineTemplate(line)
+			t := templates[text]
+			if t == nil && len(templates) < maxTemplates {
+				t = &logTemplate{text: text, counts: make([]int, numFiles)}
+				templates[text] = t
+			} else if t == nil {
+				full = true
+			}
+			if t != nil {
+				t.counts[index]++
+				if len(t.hits) < maxTemplateHits {
+					t.hits = append(t.hits, templateHit{file: index, offset: offset, time: lastTime})
+				}
+			}
+		}
+		offset += int64(len(line))
+		if err == io.EOF {
+			return templates, full, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+}
+
+// findTemplates scans all the files at once and returns their templates,
+// rarest first, and whether some lines were not counted because there were
+// too many templates
+func findTemplates(files []io.ReaderAt) ([]*logTemplate, bool, error) {
+	found := make([]map[string]*logTemplate, len(files))
+	fulls := make([]bool, len(files))
+	errs := make([]error, len(files))
+	var wg sync.WaitGroup
+	for i := range files {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			found[i], fulls[i], errs[i] = scanTemplates(files[i], i, len(files))
+		}(i)
+	}
+	wg.Wait()
+
+	merged := make(map[string]*logTemplate)
+	full := false
+	for i := range files {
+		if errs[i] != nil {
+			return nil, false, errs[i]
+		}
+		full = full || fulls[i]
+		for text, t := range found[i] {
+			m := merged[text]
+			if m == nil {
+				merged[text] = t
+				continue
+			}
+			m.counts[i] = t.counts[i]
+			m.hits = append(m.hits, t.hits...)
+		}
+	}
+
+	templates := make([]*logTemplate, 0, len(merged))
+	for _, t := range merged {
+		for _, count := range t.counts {
+			t.total += count
+		}
+		sort.SliceStable(t.hits, func(a, b int) bool {
+			return t.hits[a].time < t.hits[b].time
+		})
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(a, b int) bool {
+		if templates[a].total != templates[b].total {
+			return templates[a].total < templates[b].total
+		}
+		return templates[a].text < templates[b].text
+	})
+	return templates, full, nil
+}
+
+// parseTemplateNumber reads the number of a template as it is listed by
+// the templates command
+func parseTemplateNumber(arg string, templates []*logTemplate) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(templates) {
+		return -1, fmt.Errorf("no template %s, type templates to list them", arg)
+	}
+	return n - 1, nil
+}
+
+// nextTemplateHit returns the index of the first line of the template after
+// the time, or of its first line if there is none after it
+func nextTemplateHit(t *logTemplate, after int64) int {
+	for i, hit := range t.hits {
+		if hit.time > after {
+			return i
+		}
+	}
+	return 0
+}
+
+// describeTemplates lists the templates rarest first, numbered so that
+// they can be jumped to, with the number of lines each file has of them.
+// With partial, only the templates that some of the files do not have are
+// listed, keeping their numbers.
+func describeTemplates(fileNames []string, templates []*logTemplate, full bool, partial bool) string {
+	var report strings.Builder
+	if partial {
+		fmt.Fprintf(&report, "templates that only some of the files have, rarest first\n")
+	} else {
+		fmt.Fprintf(&report, "%s, rarest first\n", countOf(len(templates), "template"))
+	}
+	if full {
+		fmt.Fprintf(&report, "some lines are not counted, since a file has more than %d templates\n", maxTemplates)
+	}
+	names := uniqueNames(fileNames)
+	nameWidth := longestName(names)
+	fmt.Fprintf(&report, "\n%5s ", "")
+	for _, name := range names {
+		fmt.Fprintf(&report, "%*s ", nameWidth, name)
+	}
+	fmt.Fprintf(&report, " template\n")
+
+	listed := 0
+	for n, t := range templates {
+		if partial && t.files() == len(fileNames) {
+			continue
+		}
+		if listed == maxTemplatesListed {
+			fmt.Fprintf(&report, "... and more\n")
+			break
+		}
+		listed++
+		fmt.Fprintf(&report, "%4d. ", n+1)
+		for _, count := range t.counts {
+			fmt.Fprintf(&report, "%*d ", nameWidth, count)
+		}
+		fmt.Fprintf(&report, " %s\n", shortLine([]byte(t.text)))
+	}
+	if listed == 0 {
+		fmt.Fprintf(&report, "every file has every template\n")
+	}
+	return report.String()
+}
+
+// describeTemplateHit returns where a line of a template is, as it is shown
+// when jumping to it
+func describeTemplateHit(fileNames []string, n int, t *logTemplate, hit int) string {
+	more := ""
+	if len(t.hits) < t.total {
+		more = fmt.Sprintf(" (%d kept of %d)", len(t.hits), t.total)
+	}
+	return fmt.Sprintf("template %d: line %d of %d%s in %s at %s", n+1, hit+1, len(t.hits), more,
+		uniqueNames(fileNames)[t.hits[hit].file], time.Unix(0, t.hits[hit].time).UTC().Format(markTimeFormat))
+}
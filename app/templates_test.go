@@ -0,0 +1,23 @@
+package app
+
+import (
+	"fmt"
+)
+
+func Example_lineTemplate() {
+	for _, line := range []string{
+		"I[2020-05-25|08:45:33.020] Received complete proposal block module=consensus height=1 hash=FAFA7793F1AC9B3B19C9154D367CDE45318C702D5B03618097B2027EF1082BAD\n",
+		"I[2020-05-25|08:45:34.233] Received complete proposal block module=consensus height=12 hash=919CBC828BDF1CA6DD0EE76F3680646F2DCE7F9B03523834F7E3990F7A6226E6\n",
+		"E[2020-05-25|08:45:35.001] Dialing failed module=p2p addr=10.186.73.2:26656 err=\"dial tcp: i/o timeout\" attempts=3\n",
+		`{"log":"D[2020-05-25|08:45:49.514] HTTP HANDLER module=rpc-server req=\"\u0026{Method:GET URL:/broadcast_tx_commit?tx=\\\"ab\\\" Proto:HTTP/1.1}\"\n","stream":"stdout"}`,
+		"D[2020-05-25|08:45:36.120] No votes to send module=consensus localPV=BA{4:xx_x} peerPV=BA{4:____} took=1.25ms\n",
+	} {
+		fmt.Println(lineTemplate([]byte(line)))
+	}
+
+	// Output: I[<time>] Received complete proposal block module=consensus height=<n> hash=<hex>
+	// I[<time>] Received complete proposal block module=consensus height=<n> hash=<hex>
+	// E[<time>] Dialing failed module=p2p addr=<ip> err=<str> attempts=<n>
+	// {"log":"D[<time>] HTTP HANDLER module=rpc-server req=<str>\n","stream":"stdout"}
+	// D[<time>] No votes to send module=consensus localPV=BA{<bits>} peerPV=BA{<bits>} took=<n>ms
+}
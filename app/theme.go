@@ -0,0 +1,193 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+)
+
+// theme is the set of colors the views are drawn with. Every color is a
+// tview color name like "yellow" or a hex color like "#e69f00".
+type theme struct {
+	name        string
+	Background  string   // The background of every view
+	Text        string   // Log lines and other plain text
+	Border      string   // The borders of the views
+	Title       string   // The titles of the views
+	Accent      string   // Times in the status bar, the timeline and the grid, and the command box label
+	Error       string   // Error messages and timeline columns with errors
+	Activity    string   // Timeline columns without errors
+	Current     string   // The background of the current grid cell and timeline column
+	CurrentText string   // Text on the Current background
+	Muted       string   // Grid separators and gaps
+	Input       string   // The background of the command box
+	Nodes       []string // The color of each file in the merged view, repeated if there are more files
+	Auto        []string // The colors picked for "auto" highlights
+}
+
+// themePresets are the built in themes. The colorblind theme uses the
+// Okabe-Ito palette, whose colors can be told apart with any kind of
+// color blindness, and does not rely on red against green.
+var themePresets = map[string]theme{
+	"dark": {
+		Background:  "black",
+		Text:        "white",
+		Border:      "white",
+		Title:       "white",
+		Accent:      "yellow",
+		Error:       "red",
+		Activity:    "green",
+		Current:     "white",
+		CurrentText: "black",
+		Muted:       "gray",
+		Input:       "black",
+		Nodes:       []string{"aqua", "lime", "fuchsia", "yellow", "orange", "skyblue", "salmon", "violet"},
+		Auto:        []string{"aqua", "lime", "fuchsia", "yellow", "orange", "skyblue", "salmon", "violet", "springgreen", "gold", "hotpink", "lightseagreen"},
+	},
+	"light": {
+		Background:  "white",
+		Text:        "black",
+		Border:      "gray",
+		Title:       "black",
+		Accent:      "darkblue",
+		Error:       "red",
+		Activity:    "darkgreen",
+		Current:     "black",
+		CurrentText: "white",
+		Muted:       "gray",
+		Input:       "lightgray",
+		Nodes:       []string{"darkcyan", "green", "darkmagenta", "darkorange", "blue", "olive", "brown", "purple"},
+		Auto:        []string{"darkcyan", "green", "darkmagenta", "darkorange", "blue", "olive", "brown", "purple", "teal", "crimson", "darkslateblue", "chocolate"},
+	},
+	"colorblind": {
+		Background:  "black",
+		Text:        "white",
+		Border:      "white",
+		Title:       "white",
+		Accent:      "#f0e442",
+		Error:       "#d55e00",
+		Activity:    "#56b4e9",
+		Current:     "white",
+		CurrentText: "black",
+		Muted:       "gray",
+		Input:       "black",
+		Nodes:       []string{"#e69f00", "#56b4e9", "#009e73", "#f0e442", "#0072b2", "#d55e00", "#cc79a7"},
+		Auto:        []string{"#e69f00", "#56b4e9", "#009e73", "#f0e442", "#0072b2", "#d55e00", "#cc79a7"},
+	},
+}
+
+// currentTheme is the theme the views are drawn with
+var currentTheme, _ = newTheme("", nil)
+
+// isColor tells whether the name is a color tview understands
+func isColor(name string) bool {
+	if _, ok := tcell.ColorNames[name]; ok {
+		return true
+	}
+	if len(name) == 7 && name[0] == '#' {
+		_, err := strconv.ParseUint(name[1:], 16, 32)
+		return err == nil
+	}
+	return false
+}
+
+// colorFields returns the single colors of the theme by the names used for
+// them in the config file
+func (t *theme) colorFields() map[string]*string {
+	return map[string]*string{
+		"background":   &t.Background,
+		"text":         &t.Text,
+		"border":       &t.Border,
+		"title":        &t.Title,
+		"accent":       &t.Accent,
+		"error":        &t.Error,
+		"activity":     &t.Activity,
+		"current":      &t.Current,
+		"current_text": &t.CurrentText,
+		"muted":        &t.Muted,
+		"input":        &t.Input,
+	}
+}
+
+// newTheme creates a theme from the preset with the overrides applied.
+// The overrides map the names of colors in the theme, like "accent", to
+// colors, and "nodes" and "auto" to comma separated lists of colors.
+// An empty preset is the dark theme. If some of the overrides are bad,
+// the theme is still returned along with an error that lists them.
+func newTheme(preset string, overrides map[string]string) (*theme, error) {
+	if preset == "" {
+		preset = "dark"
+	}
+	presetTheme, ok := themePresets[preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown theme %q, expected dark, light or colorblind", preset)
+	}
+
+	t := presetTheme
+	t.name = preset
+	fields := t.colorFields()
+	var problems []string
+	for name, value := range overrides {
+		value = strings.TrimSpace(value)
+		if name == "nodes" || name == "auto" {
+			colors := strings.Split(value, ",")
+			for i := range colors {
+				colors[i] = strings.TrimSpace(colors[i])
+				if !isColor(colors[i]) {
+					problems = append(problems, fmt.Sprintf("unknown color %q for %q", colors[i], name))
+				}
+			}
+			if name == "nodes" {
+				t.Nodes = colors
+			} else {
+				t.Auto = colors
+			}
+			continue
+		}
+
+		field, ok := fields[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown theme color %q", name))
+		} else if !isColor(value) {
+			problems = append(problems, fmt.Sprintf("unknown color %q for %q", value, name))
+		} else {
+			*field = value
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return &t, fmt.Errorf("bad theme colors: %s", strings.Join(problems, ", "))
+	}
+	return &t, nil
+}
+
+// themeColor returns the tcell color for one of the colors of the theme
+func themeColor(name string) tcell.Color {
+	return tcell.GetColor(name)
+}
+
+// apply sets the tview styles from the theme. It has to be called before
+// the views are created, since they take their colors from the styles.
+func (t *theme) apply() {
+	tview.Styles.PrimitiveBackgroundColor = themeColor(t.Background)
+	tview.Styles.ContrastBackgroundColor = themeColor(t.Input)
+	tview.Styles.BorderColor = themeColor(t.Border)
+	tview.Styles.TitleColor = themeColor(t.Title)
+	tview.Styles.GraphicsColor = themeColor(t.Border)
+	tview.Styles.PrimaryTextColor = themeColor(t.Text)
+	tview.Styles.SecondaryTextColor = themeColor(t.Accent)
+	tview.Styles.TertiaryTextColor = themeColor(t.Activity)
+	tview.Styles.InverseTextColor = themeColor(t.CurrentText)
+	tview.Styles.ContrastSecondaryTextColor = themeColor(t.Accent)
+}
+
+// style returns the tcell style for drawing directly on the screen with the
+// foreground color on the background of the theme
+func (t *theme) style(fg string) tcell.Style {
+	return tcell.StyleDefault.Foreground(themeColor(fg)).Background(themeColor(t.Background))
+}
@@ -0,0 +1,180 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// minEpochDigits is the smallest number of digits that we treat as a unix
+// epoch instead of a number of steps. Ten digits is a unix time in seconds
+// from 2001 onward, and nobody steps a billion log lines at a time.
+const minEpochDigits = 10
+
+// TimeReference holds the times that user time input can be relative to.
+// All the times are unix nanoseconds.
+type TimeReference struct {
+	Current      int64 // The current time of the cluster, used for the date of time-of-day input
+	Start        int64 // The earliest first log line of all the files, used for "start+90s"
+	End          int64 // The latest last log line of all the files, used for "end-2m"
+	OverlapStart int64 // The latest first log line of all the files, used for percentages
+	OverlapEnd   int64 // The earliest last log line of all the files, used for percentages
+}
+
+// dateTimeLayouts are the layouts for full dates and times that we accept.
+// Layouts without a zone are treated as UTC like the log timestamps.
+var dateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// timeOfDayLayouts are the layouts for a time of day without a date.
+var timeOfDayLayouts = []string{
+	"15:04:05.999999999",
+	"15:04",
+}
+
+// isEpochInput tells whether the input is a unix epoch rather than
+// a number of steps or some other command.
+func isEpochInput(input string) bool {
+	intPart := input
+	if dot := strings.IndexByte(input, '.'); dot >= 0 {
+		intPart = input[:dot]
+		if _, err := strconv.ParseUint(input[dot+1:], 10, 64); err != nil {
+			return false
+		}
+	}
+	if len(intPart) < minEpochDigits {
+		return false
+	}
+	_, err := strconv.ParseUint(intPart, 10, 64)
+	return err == nil
+}
+
+// parseEpoch converts a unix epoch in seconds, milliseconds, microseconds
+// or nanoseconds into nanoseconds. The unit is picked from the number of
+// digits, and seconds may have a fractional part.
+func parseEpoch(input string) (int64, error) {
+	if dot := strings.IndexByte(input, '.'); dot >= 0 {
+		seconds, err := strconv.ParseInt(input[:dot], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		fraction := (input[dot+1:] + "000000000")[:9]
+		nanos, err := strconv.ParseInt(fraction, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return seconds*int64(time.Second) + nanos, nil
+	}
+
+	epoch, err := strconv.ParseInt(input, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case len(input) <= 10:
+		return epoch * int64(time.Second), nil
+	case len(input) <= 13:
+		return epoch * int64(time.Millisecond), nil
+	case len(input) <= 16:
+		return epoch * int64(time.Microsecond), nil
+	}
+	return epoch, nil
+}
+
+// parseRelativeTime parses input like "start", "start+90s" or "end-2m".
+// The boolean result tells whether the input started with one of the anchors.
+func parseRelativeTime(input string, ref TimeReference) (int64, bool, error) {
+	anchors := []struct {
+		name string
+		time int64
+	}{
+		{"start", ref.Start},
+		{"end", ref.End},
+	}
+
+	for _, anchor := range anchors {
+		if !strings.HasPrefix(input, anchor.name) {
+			continue
+		}
+		offset := strings.TrimSpace(input[len(anchor.name):])
+		if offset == "" {
+			return anchor.time, true, nil
+		}
+		if offset[0] != '+' && offset[0] != '-' {
+			return 0, true, fmt.Errorf("expected + or - after %q", anchor.name)
+		}
+		duration, err := time.ParseDuration(strings.Replace(offset, " ", "", -1))
+		if err != nil {
+			return 0, true, fmt.Errorf("bad duration %q", offset)
+		}
+		return anchor.time + int64(duration), true, nil
+	}
+
+	return 0, false, nil
+}
+
+// parsePercent parses input like "25%" as that percentage of the way
+// through the time range where all the files overlap.
+func parsePercent(input string, ref TimeReference) (int64, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(input, "%")), 64)
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, fmt.Errorf("bad percentage %q, expected 0%% to 100%%", input)
+	}
+	if ref.OverlapEnd < ref.OverlapStart {
+		return 0, errors.New("the files do not overlap in time")
+	}
+	return ref.OverlapStart + int64(float64(ref.OverlapEnd-ref.OverlapStart)*percent/100), nil
+}
+
+// ParseTimeInput converts the time typed into the command box into unix
+// nanoseconds. It accepts the log format "2020-05-25|08:47:33.663",
+// RFC3339 with or without a zone, unix epochs in seconds, milliseconds,
+// microseconds or nanoseconds, a time of day on the same date as the
+// current position, "start" or "end" plus or minus a duration, and a
+// percentage of the time range where all the files overlap.
+func ParseTimeInput(input string, ref TimeReference) (int64, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return 0, errors.New("no time given")
+	}
+
+	if strings.HasSuffix(input, "%") {
+		return parsePercent(input, ref)
+	}
+
+	if relativeTime, ok, err := parseRelativeTime(input, ref); ok {
+		return relativeTime, err
+	}
+
+	if isEpochInput(input) {
+		return parseEpoch(input)
+	}
+
+	if timeStamp := filechunk.GetTimeStampFromLine(input); timeStamp > 1 {
+		return timeStamp, nil
+	}
+
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, input); err == nil {
+			return t.UnixNano(), nil
+		}
+	}
+
+	for _, layout := range timeOfDayLayouts {
+		if t, err := time.Parse(layout, input); err == nil {
+			curr := time.Unix(0, ref.Current).UTC()
+			t = time.Date(curr.Year(), curr.Month(), curr.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+			return t.UnixNano(), nil
+		}
+	}
+
+	return 0, fmt.Errorf("could not parse %q as a time", input)
+}
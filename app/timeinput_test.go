@@ -0,0 +1,82 @@
+// Package app_test tests the time input parsing of the command box
+package app_test
+
+import (
+	"fmt"
+
+	"github.com/joecroninallen/logsync/app"
+)
+
+// exampleReference is a cluster whose files run from 08:45:31.000
+// to 08:47:33.000, and all overlap from 08:45:32.000 to 08:47:32.000
+var exampleReference = app.TimeReference{
+	Current:      1590396400000000000,
+	Start:        1590396331000000000,
+	End:          1590396453000000000,
+	OverlapStart: 1590396332000000000,
+	OverlapEnd:   1590396452000000000,
+}
+
+func ExampleParseTimeInput() {
+	inputs := []string{
+		"2020-05-25|08:47:33.663",
+		"2020-05-25T08:47:33.663Z",
+		"2020-05-25T10:47:33.663+02:00",
+		"2020-05-25 08:47:33.663",
+		"1590396453",
+		"1590396453.663",
+		"1590396453663",
+		"1590396453663000000",
+		"08:47:33.663",
+		"08:47",
+		"start",
+		"start+90s",
+		"end - 2m",
+		"0%",
+		"50%",
+		"100%",
+	}
+
+	for _, input := range inputs {
+		timeStamp, err := app.ParseTimeInput(input, exampleReference)
+		fmt.Println(input, "->", timeStamp, err)
+	}
+
+	// Output: 2020-05-25|08:47:33.663 -> 1590396453663000000 <nil>
+	// 2020-05-25T08:47:33.663Z -> 1590396453663000000 <nil>
+	// 2020-05-25T10:47:33.663+02:00 -> 1590396453663000000 <nil>
+	// 2020-05-25 08:47:33.663 -> 1590396453663000000 <nil>
+	// 1590396453 -> 1590396453000000000 <nil>
+	// 1590396453.663 -> 1590396453663000000 <nil>
+	// 1590396453663 -> 1590396453663000000 <nil>
+	// 1590396453663000000 -> 1590396453663000000 <nil>
+	// 08:47:33.663 -> 1590396453663000000 <nil>
+	// 08:47 -> 1590396420000000000 <nil>
+	// start -> 1590396331000000000 <nil>
+	// start+90s -> 1590396421000000000 <nil>
+	// end - 2m -> 1590396333000000000 <nil>
+	// 0% -> 1590396332000000000 <nil>
+	// 50% -> 1590396392000000000 <nil>
+	// 100% -> 1590396452000000000 <nil>
+}
+
+func ExampleParseTimeInput_errors() {
+	inputs := []string{
+		"",
+		"yesterday",
+		"start*2",
+		"end-forever",
+		"150%",
+	}
+
+	for _, input := range inputs {
+		_, err := app.ParseTimeInput(input, exampleReference)
+		fmt.Println(err)
+	}
+
+	// Output: no time given
+	// could not parse "yesterday" as a time
+	// expected + or - after "start"
+	// bad duration "-forever"
+	// bad percentage "150%", expected 0% to 100%
+}
@@ -0,0 +1,265 @@
+package app
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// errorLinePattern matches the log lines that are counted as errors in the
+// timeline. This is the tendermint error level prefix and the usual level
+// fields of structured loggers.
+var errorLinePattern = regexp.MustCompile(`\bE\[\d{4}-|level=error|"level":"error"`)
+
+// densityRunes are the characters used to draw the density of a time bucket,
+// from least to most dense.
+var densityRunes = []rune(" ▁▂▃▄▅▆▇█")
+
+// restartRune marks the time buckets where a file restarts
+const restartRune = '┃'
+
+// timelineLabelWidth is the width of the file name label on each timeline row
+const timelineLabelWidth = 10
+
+// timelineTimeFormat is how the start and end times are shown on the timeline axis
+const timelineTimeFormat = "15:04:05.000"
+
+// timeline is a minimap along the bottom of the UI with one row per file.
+// Each column is a bucket of time over the range where all the files overlap,
+// and it shows how many bytes of log lines each file has in that bucket,
+// whether any of them are errors and whether the file restarts in it. The
+// column of the current cluster time is highlighted, and clicking a column
+// jumps all the files to that time.
+//
+// The bucket boundaries are found by a binary search over each file with
+// filechunk.FindOffsetForTime, so the density can be drawn without reading
+// every line. The error lines are found by a scan of each file that runs in
+// the background and fills in the error markers when it is done.
+type timeline struct {
+	*tview.Box
+	app           *tview.Application // The application, used to redraw once the error scans finish
+	fileViews     []fileView         // All the fileViews that the timeline shows
+	startTime     int64              // The time at the left edge of the timeline
+	endTime       int64              // The time at the right edge of the timeline
+	bucketOffsets [][]int64          // For each file, the file offsets of the bucket boundaries
+	errorOffsets  [][]int64          // For each file, the sorted offsets of error lines, nil until scanned
+	scanStarted   bool               // Whether the background error scans have been started
+	beforeJump    func()             // Called before a click jumps the files, if set
+}
+
+// newTimeline creates the timeline for all the fileViews
+func newTimeline(app *tview.Application, fileViews []fileView) *timeline {
+	return &timeline{
+		Box:          tview.NewBox(),
+		app:          app,
+		fileViews:    fileViews,
+		errorOffsets: make([][]int64, len(fileViews)),
+	}
+}
+
+// timelineHeight is the number of rows the timeline needs
+// for the given number of files, including the time axis.
+func timelineHeight(numFiles int) int {
+	return numFiles + 1
+}
+
+// scanErrors reads the whole file in the background and collects the
+// offsets of every line that matches errorLinePattern.
+func scanErrors(fv *fileView) []int64 {
+	var errorOffsets []int64
+	buf := make([]byte, 262144)
+	var offset int64
+	var partial []byte
+	for {
+		n, err := fv.file.ReadAt(buf, offset)
+		data := append(partial, buf[:n]...)
+		dataOffset := offset - int64(len(partial))
+		lineStart := 0
+		for {
+			newlineIndex := bytes.IndexByte(data[lineStart:], '\n')
+			if newlineIndex < 0 {
+				break
+			}
+			if errorLinePattern.Match(data[lineStart : lineStart+newlineIndex]) {
+				errorOffsets = append(errorOffsets, dataOffset+int64(lineStart))
+			}
+			lineStart += newlineIndex + 1
+		}
+		partial = append([]byte(nil), data[lineStart:]...)
+		offset += int64(n)
+		if err == io.EOF || n == 0 {
+			break
+		}
+	}
+	if len(partial) > 0 && errorLinePattern.Match(partial) {
+		errorOffsets = append(errorOffsets, offset-int64(len(partial)))
+	}
+	return errorOffsets
+}
+
+// startErrorScans starts a background scan of each file for error lines
+func (tl *timeline) startErrorScans() {
+	tl.scanStarted = true
+	for i := range tl.fileViews {
+		go func(i int) {
+			errorOffsets := scanErrors(&tl.fileViews[i])
+			tl.app.QueueUpdateDraw(func() {
+				tl.errorOffsets[i] = errorOffsets
+			})
+		}(i)
+	}
+}
+
+// updateBuckets sets the time range of the timeline and finds the file
+// offsets of the boundaries of numBuckets buckets in every file.
+func (tl *timeline) updateBuckets(numBuckets int) {
+	ref := GetTimeReference(tl.fileViews)
+	tl.startTime, tl.endTime = ref.OverlapStart, ref.OverlapEnd
+	if tl.endTime <= tl.startTime {
+		tl.startTime, tl.endTime = ref.Start, ref.End
+	}
+
+	tl.bucketOffsets = make([][]int64, len(tl.fileViews))
+	if numBuckets < 1 || tl.endTime <= tl.startTime {
+		return
+	}
+	for i := range tl.fileViews {
+		offsets := make([]int64, numBuckets+1)
+		for b := range offsets {
+			offsets[b] = filechunk.FindOffsetForTime(tl.fileViews[i].file, tl.bucketTime(b, numBuckets))
+		}
+		tl.bucketOffsets[i] = offsets
+	}
+}
+
+// bucketTime returns the time at the start of the bucket
+func (tl *timeline) bucketTime(bucket int, numBuckets int) int64 {
+	return tl.startTime + (tl.endTime-tl.startTime)*int64(bucket)/int64(numBuckets)
+}
+
+// countErrors returns the number of error lines between the two offsets
+func countErrors(errorOffsets []int64, start int64, end int64) int {
+	from := sort.Search(len(errorOffsets), func(i int) bool { return errorOffsets[i] >= start })
+	to := sort.Search(len(errorOffsets), func(i int) bool { return errorOffsets[i] >= end })
+	return to - from
+}
+
+// hasRestart returns whether the file restarts between the two offsets
+func hasRestart(restarts []*restart, start int64, end int64) bool {
+	i := sort.Search(len(restarts), func(i int) bool { return restarts[i].offset >= start })
+	return i < len(restarts) && restarts[i].offset < end
+}
+
+// timelineLabel shortens the name of the file, as uniqueNames gives it, to
+// fit in the label of a timeline row
+func timelineLabel(name string) string {
+	label := strings.TrimSuffix(name, filepath.Ext(name))
+	if len(label) > timelineLabelWidth-1 {
+		label = label[:timelineLabelWidth-1]
+	}
+	return label
+}
+
+// Draw draws the time axis and a density row for every file
+func (tl *timeline) Draw(screen tcell.Screen) {
+	tl.Box.Draw(screen)
+	x, y, width, height := tl.GetInnerRect()
+	numBuckets := width - timelineLabelWidth
+	if numBuckets < 1 || height < 1 {
+		return
+	}
+
+	if !tl.scanStarted {
+		tl.startErrorScans()
+	}
+	if len(tl.bucketOffsets) == 0 || len(tl.bucketOffsets[0]) != numBuckets+1 {
+		tl.updateBuckets(numBuckets)
+	}
+	if tl.endTime <= tl.startTime {
+		tview.Print(screen, "no time range to show", x, y, width, tview.AlignLeft, themeColor(currentTheme.Accent))
+		return
+	}
+
+	currBucket := -1
+	if clusterTime := CurrentClusterTime(tl.fileViews); clusterTime >= tl.startTime && clusterTime <= tl.endTime {
+		currBucket = int((clusterTime - tl.startTime) * int64(numBuckets) / (tl.endTime - tl.startTime))
+		if currBucket == numBuckets {
+			currBucket--
+		}
+	}
+
+	// The time axis shows the start and end of the range
+	startLabel := time.Unix(0, tl.startTime).UTC().Format(timelineTimeFormat)
+	endLabel := time.Unix(0, tl.endTime).UTC().Format(timelineTimeFormat)
+	tview.Print(screen, startLabel, x+timelineLabelWidth, y, numBuckets, tview.AlignLeft, themeColor(currentTheme.Accent))
+	tview.Print(screen, endLabel, x+timelineLabelWidth, y, numBuckets, tview.AlignRight, themeColor(currentTheme.Accent))
+
+	names := uniqueNames(logFilenames(tl.fileViews))
+	for i := range tl.fileViews {
+		row := y + 1 + i
+		if row >= y+height {
+			break
+		}
+		tview.Print(screen, tview.Escape(timelineLabel(names[i])), x, row, timelineLabelWidth, tview.AlignLeft, themeColor(currentTheme.Text))
+
+		offsets := tl.bucketOffsets[i]
+		var maxBytes int64 = 1
+		for b := 0; b < numBuckets; b++ {
+			if bucketBytes := offsets[b+1] - offsets[b]; bucketBytes > maxBytes {
+				maxBytes = bucketBytes
+			}
+		}
+
+		for b := 0; b < numBuckets; b++ {
+			bucketBytes := offsets[b+1] - offsets[b]
+			level := int(bucketBytes * int64(len(densityRunes)-1) / maxBytes)
+			if level == 0 && bucketBytes > 0 {
+				level = 1
+			}
+
+			densityRune := densityRunes[level]
+			style := currentTheme.style(currentTheme.Activity)
+			if countErrors(tl.errorOffsets[i], offsets[b], offsets[b+1]) > 0 {
+				style = style.Foreground(themeColor(currentTheme.Error))
+			}
+			if hasRestart(tl.fileViews[i].restarts, offsets[b], offsets[b+1]) {
+				densityRune = restartRune
+				style = style.Foreground(themeColor(currentTheme.Accent))
+			}
+			if b == currBucket {
+				style = style.Background(themeColor(currentTheme.Current))
+			}
+			screen.SetContent(x+timelineLabelWidth+b, row, densityRune, nil, style)
+		}
+	}
+}
+
+// MouseHandler jumps all the files to the time of the clicked column
+func (tl *timeline) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return tl.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		mouseX, mouseY := event.Position()
+		if !tl.InRect(mouseX, mouseY) || action != tview.MouseLeftClick {
+			return false, nil
+		}
+
+		x, _, width, _ := tl.GetInnerRect()
+		numBuckets := width - timelineLabelWidth
+		bucket := mouseX - x - timelineLabelWidth
+		if bucket >= 0 && bucket < numBuckets && tl.endTime > tl.startTime {
+			if tl.beforeJump != nil {
+				tl.beforeJump()
+			}
+			MoveAllToTime(tl.fileViews, tl.bucketTime(bucket, numBuckets))
+		}
+		return true, nil
+	})
+}
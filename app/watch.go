@@ -0,0 +1,292 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gdamore/tcell"
+	"github.com/rivo/tview"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+// maxWatchLookback is how many lines before the current line are searched
+// for a watched value before giving up, so that a value that is not in a
+// file does not make every step read the whole file
+const maxWatchLookback = 10000
+
+// watchColumnWidth is the most columns a watched value takes up in the panel
+const watchColumnWidth = 24
+
+// watchValue is the value of a watch in one file the last time it was
+// looked up, which lets the next lookup after a step look at only the
+// lines the step went past
+type watchValue struct {
+	chunkOffset int64  // The offset of the current line the value was looked up for
+	valueOffset int64  // The offset of the line the value is from, or -1 if none was found
+	value       string // The value
+}
+
+// watch is an expression whose latest value in every file is shown in the
+// watch panel. It is either a field written as key=value or as "key":"value"
+// in JSON lines, or a regular expression whose first group, or whole match
+// if it has none, is the value.
+type watch struct {
+	Name  string `json:"name"`            // The name shown in the panel
+	Field string `json:"field,omitempty"` // The field whose value is watched
+	Match string `json:"match,omitempty"` // The regular expression that finds the value
+
+	pattern *regexp.Regexp // The compiled Match, or the pattern that finds the field
+	values  []*watchValue  // The last value looked up in each file
+}
+
+// compile checks the watch and compiles its regular expression
+func (w *watch) compile() error {
+	if !fieldNamePattern.MatchString(w.Name) {
+		return fmt.Errorf("bad watch name %q, expected a word like height", w.Name)
+	}
+	switch {
+	case w.Match != "" && w.Field != "":
+		return fmt.Errorf("watch %s has either a match or a field, not both", w.Name)
+	case w.Match != "":
+		pattern, err := regexp.Compile(w.Match)
+		if err != nil {
+			return fmt.Errorf("bad match for watch %s: %v", w.Name, err)
+		}
+		w.pattern = pattern
+	default:
+		if w.Field == "" {
+			w.Field = w.Name
+		}
+		w.pattern = fieldValuePattern(w.Field)
+	}
+	w.values = nil
+	return nil
+}
+
+// describe returns the watch as it is shown in the list of watches
+func (w *watch) describe() string {
+	if w.Match != "" {
+		return w.Name + " " + w.Match
+	}
+	if w.Field != w.Name {
+		return w.Name + " " + w.Field + "="
+	}
+	return w.Name
+}
+
+// find returns the value in the line, and whether there is one
+func (w *watch) find(line []byte) (string, bool) {
+	match := w.pattern.FindSubmatch(line)
+	if match == nil {
+		return "", false
+	}
+	if len(match) > 1 {
+		return string(match[1]), true
+	}
+	return string(match[0]), true
+}
+
+// valueAt returns the value of the watch in the latest line at or before
+// the chunk, or an empty string if there is none. When the chunk is after
+// the one of the last lookup, only the lines in between are looked at, and
+// when it is before it but still after the line the value came from, the
+// value has not changed.
+func (w *watch) valueAt(fileIndex int, chunk *filechunk.FileChunk) string {
+	for len(w.values) <= fileIndex {
+		w.values = append(w.values, nil)
+	}
+	cached := w.values[fileIndex]
+	if cached != nil {
+		if chunk.FileOffsetStart == cached.chunkOffset {
+			return cached.value
+		}
+		if chunk.FileOffsetStart < cached.chunkOffset && cached.valueOffset >= 0 && chunk.FileOffsetStart >= cached.valueOffset {
+			cached.chunkOffset = chunk.FileOffsetStart
+			return cached.value
+		}
+	}
+
+	result := &watchValue{chunkOffset: chunk.FileOffsetStart, valueOffset: -1}
+	line := chunk
+	for i := 0; line != nil && i < maxWatchLookback; i++ {
+		if cached != nil && chunk.FileOffsetStart > cached.chunkOffset && line.FileOffsetStart <= cached.chunkOffset {
+			result.value, result.valueOffset = cached.value, cached.valueOffset
+			break
+		}
+		if value, ok := w.find(line.FileChunkBytes); ok {
+			result.value, result.valueOffset = value, line.FileOffsetStart
+			break
+		}
+		line = line.GetPrevFileChunk()
+	}
+	w.values[fileIndex] = result
+	return result.value
+}
+
+// watchList is the watches shown in the watch panel, in the order they
+// were added
+type watchList struct {
+	watches []*watch
+}
+
+// add adds the watch, replacing the one with the same name if there is one
+func (wl *watchList) add(w *watch) error {
+	if err := w.compile(); err != nil {
+		return err
+	}
+	for i := range wl.watches {
+		if wl.watches[i].Name == w.Name {
+			wl.watches[i] = w
+			return nil
+		}
+	}
+	wl.watches = append(wl.watches, w)
+	return nil
+}
+
+// remove removes the watch with the name, and returns whether there was one
+func (wl *watchList) remove(name string) bool {
+	for i := range wl.watches {
+		if wl.watches[i].Name == name {
+			wl.watches = append(wl.watches[:i], wl.watches[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// describe lists the watches
+func (wl *watchList) describe() string {
+	if len(wl.watches) == 0 {
+		return "no watches"
+	}
+	var parts []string
+	for _, w := range wl.watches {
+		parts = append(parts, w.describe())
+	}
+	return "watching " + strings.Join(parts, ", ")
+}
+
+// parseWatchArgs makes a watch from the arguments of the watch command,
+// which are a name and optionally a regular expression or a field as
+// FIELD=. With only a name, the field with that name is watched.
+func parseWatchArgs(args []string) *watch {
+	w := &watch{Name: args[0]}
+	if len(args) > 1 {
+		expr := strings.Join(args[1:], " ")
+		if field := strings.TrimSuffix(expr, "="); field != expr && fieldNamePattern.MatchString(field) {
+			w.Field = field
+		} else {
+			w.Match = expr
+		}
+	}
+	return w
+}
+
+// watchPanel shows the latest value of every watch in every file at the
+// current position, with a row for each file. Values that are not the same
+// in all the files are drawn in the error color of the theme, except for
+// the most common one.
+type watchPanel struct {
+	*tview.Box
+	fileViews []fileView
+	watches   *watchList
+}
+
+// newWatchPanel creates the watch panel for all the fileViews
+func newWatchPanel(fileViews []fileView, watches *watchList) *watchPanel {
+	return &watchPanel{
+		Box:       tview.NewBox(),
+		fileViews: fileViews,
+		watches:   watches,
+	}
+}
+
+// height returns how many rows the panel needs, which is none if there
+// is nothing to watch
+func (wp *watchPanel) height() int {
+	if len(wp.watches.watches) == 0 {
+		return 0
+	}
+	return len(wp.fileViews) + 1
+}
+
+// Draw looks up the values at the current position and draws them
+func (wp *watchPanel) Draw(screen tcell.Screen) {
+	wp.Box.Draw(screen)
+	x, y, width, height := wp.GetInnerRect()
+	if len(wp.watches.watches) == 0 || height < 1 {
+		return
+	}
+
+	values := make([][]string, len(wp.watches.watches))
+	for w, watch := range wp.watches.watches {
+		values[w] = make([]string, len(wp.fileViews))
+		for i := range wp.fileViews {
+			values[w][i] = watch.valueAt(i, wp.fileViews[i].currChunk)
+		}
+	}
+
+	names := uniqueNames(logFilenames(wp.fileViews))
+	labelWidth := longestName(names) + 2
+	columnX := x + labelWidth
+	for w, watch := range wp.watches.watches {
+		columnWidth := len(watch.Name)
+		for _, value := range values[w] {
+			if len(value) > columnWidth {
+				columnWidth = len(value)
+			}
+		}
+		if columnWidth > watchColumnWidth {
+			columnWidth = watchColumnWidth
+		}
+		if columnX >= x+width {
+			break
+		}
+
+		tview.Print(screen, tview.Escape(watch.Name), columnX, y, columnWidth, tview.AlignLeft, themeColor(currentTheme.Accent))
+		common := mostCommon(values[w])
+		for i, value := range values[w] {
+			row := y + 1 + i
+			if row >= y+height {
+				break
+			}
+			valueColor := currentTheme.Text
+			if value == "" {
+				value, valueColor = "-", currentTheme.Muted
+			} else if value != common {
+				valueColor = currentTheme.Error
+			}
+			tview.Print(screen, tview.Escape(value), columnX, row, columnWidth, tview.AlignLeft, themeColor(valueColor))
+		}
+		columnX += columnWidth + 2
+	}
+
+	for i := range wp.fileViews {
+		row := y + 1 + i
+		if row >= y+height {
+			break
+		}
+		tview.Print(screen, tview.Escape(names[i]), x, row, labelWidth, tview.AlignLeft, themeColor(currentTheme.Text))
+	}
+}
+
+// mostCommon returns the value that the most files have, preferring the
+// first one when there is a tie. Empty values, from files where the value
+// has not been seen yet, are not counted.
+func mostCommon(values []string) string {
+	counts := make(map[string]int)
+	var common string
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		counts[value]++
+		if counts[value] > counts[common] || common == "" {
+			common = value
+		}
+	}
+	return common
+}
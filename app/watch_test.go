@@ -0,0 +1,58 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/joecroninallen/logsync/filechunk"
+)
+
+func Example_watch_valueAt() {
+	fileViews := openLogFileViews(
+		"I[2020-05-25|08:45:30.000] Committed state height=1\n" +
+			"I[2020-05-25|08:45:30.100] Timed out round=0\n" +
+			"I[2020-05-25|08:45:30.200] Timed out round=1\n" +
+			"I[2020-05-25|08:45:30.300] Committed state height=2\n" +
+			"I[2020-05-25|08:45:30.400] Timed out round=0\n",
+	)
+	var chunks []*filechunk.FileChunk
+	for chunk := fileViews[0].currChunk; chunk != nil; chunk = chunk.GetNextFileChunk() {
+		chunks = append(chunks, chunk)
+	}
+
+	w := &watch{Name: "height"}
+	w.compile()
+	// lookUp prints the value at the line and the line the cached value
+	// is from, and checks it against a lookup without the cache
+	lookUp := func(i int) {
+		value := w.valueAt(0, chunks[i])
+		fresh := &watch{Name: "height"}
+		fresh.compile()
+		fmt.Println(i, value, w.values[0].valueOffset, value == fresh.valueAt(0, chunks[i]))
+	}
+	for i := range chunks {
+		lookUp(i)
+	}
+	for i := len(chunks) - 1; i >= 0; i-- {
+		lookUp(i)
+	}
+
+	// The cached value is used without reading its line again, both going
+	// forward past it and going back to it, so changing the line only
+	// shows once a lookup has to read it
+	lookUp(3)
+	chunks[3].FileChunkBytes = []byte("I[2020-05-25|08:45:30.300] Committed state height=7\n")
+	fmt.Println(w.valueAt(0, chunks[4]), w.valueAt(0, chunks[3]), w.valueAt(0, chunks[2]), w.valueAt(0, chunks[3]))
+
+	// Output: 0 1 0 true
+	// 1 1 0 true
+	// 2 1 0 true
+	// 3 2 142 true
+	// 4 2 142 true
+	// 4 2 142 true
+	// 3 2 142 true
+	// 2 1 0 true
+	// 1 1 0 true
+	// 0 1 0 true
+	// 3 2 142 true
+	// 2 2 1 7
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/joecroninallen/logsync/app"
+	"github.com/spf13/cobra"
+)
+
+var divergeKey string
+var divergeValue string
+
+// divergeCmd finds the first key, like a height, where the log files have
+// different values, like app hashes, without starting the UI
+var divergeCmd = &cobra.Command{
+	Use:   "diverge [--key KEY] [--value VALUE] [list of log files]",
+	Short: "Find the first key where the log files disagree on a value",
+	Long: `Find the first key where the log files disagree on a value, like the first
+	height where the nodes have different app hashes. KEY and VALUE are each a field
+	name, like height, or a regular expression whose first group is the value.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := app.ReportDivergence(os.Stdout, args, divergeKey, divergeValue); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	divergeCmd.Flags().StringVar(&divergeKey, "key", "height", "field or regular expression of the key the files should agree at")
+	divergeCmd.Flags().StringVar(&divergeValue, "value", "appHash", "field or regular expression of the value the files should agree on")
+	rootCmd.AddCommand(divergeCmd)
+}
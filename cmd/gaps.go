@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joecroninallen/logsync/app"
+	"github.com/spf13/cobra"
+)
+
+var gapsMin time.Duration
+var gapsPattern string
+
+// gapsCmd lists the times the log files went silent without starting the UI
+var gapsCmd = &cobra.Command{
+	Use:   "gaps [--min DURATION] [--pattern REGEX] [list of log files]",
+	Short: "List the times a log file has no lines for longer than a duration",
+	Long: `List the times a log file has no lines for longer than a duration, longest first.
+	With --pattern, only the lines that match it are counted, so that a missing
+	heartbeat message can be found in a file that keeps logging other things.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if gapsMin <= 0 {
+			fmt.Println("the gap duration has to be more than 0")
+			os.Exit(1)
+		}
+		if err := app.ReportGaps(os.Stdout, args, gapsMin, gapsPattern); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	gapsCmd.Flags().DurationVar(&gapsMin, "min", 2*time.Second, "shortest silence that is listed")
+	gapsCmd.Flags().StringVar(&gapsPattern, "pattern", "", "only count the lines that match this regular expression")
+	rootCmd.AddCommand(gapsCmd)
+}
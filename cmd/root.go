@@ -30,19 +30,41 @@ import (
 )
 
 var cfgFile string
+var sessionFile string
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "logsync [list of log files]",
+	Use:   "logsync [list of log files] | --session file",
 	Short: "Synchronize location of multiple log files from distributed application",
 	Long: `Synchronize location of multiple log files.
 	Also allows for stepping forward or backward in time to get a rough idea of 
 	how various nodes of a distributed application looked at various moments in time.`,
-	Args: cobra.MinimumNArgs(1),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if sessionFile != "" {
+			if len(args) > 0 {
+				return fmt.Errorf("give either log files or --session, not both")
+			}
+			return nil
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
 	//PreRun: func(cmd *Command, args []string) {
 	//},
 	Run: func(cmd *cobra.Command, args []string) {
-		app.RunLogSync(args)
+		config, err := app.LoadConfig(cfgFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		var session *app.Session
+		if sessionFile != "" {
+			session, args, err = app.LoadSession(sessionFile)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+		app.RunLogSync(args, config, session)
 	},
 }
 
@@ -56,4 +78,6 @@ func Execute() {
 }
 
 func init() {
+	rootCmd.Flags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.logsync.json)")
+	rootCmd.Flags().StringVar(&sessionFile, "session", "", "session file saved with the save command to open again")
 }
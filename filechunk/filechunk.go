@@ -2,6 +2,7 @@
 package filechunk
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -324,12 +325,134 @@ func (fc *FileChunk) ValidateFileChunkChain() bool {
 	return true
 }
 
+// CountLines counts the newlines in the file from the start offset up to
+// but not including the end offset. This is how we figure out the line
+// number of a chunk without having broken the whole file into lines.
+// The file is read with ReadAt in blocks of defaultChunkSize so that this
+// does not move the file offset used when loading chunks.
+func CountLines(f *os.File, start int64, end int64) int64 {
+	buf := make([]byte, defaultChunkSize)
+	var count int64
+	for start < end {
+		readSize := end - start
+		if readSize > defaultChunkSize {
+			readSize = defaultChunkSize
+		}
+		n, err := f.ReadAt(buf[:readSize], start)
+		count += int64(bytes.Count(buf[:n], []byte{'\n'}))
+		if err == io.EOF {
+			break
+		}
+		check(err)
+		start += int64(n)
+	}
+	return count
+}
+
+// probeSize is how many bytes we read at a time when probing
+// the file for a single log line during a binary search.
+const probeSize int64 = 4096
+
+// readLineAt reads the log line that starts at offset, including its
+// newline, reading probeSize bytes at a time until the newline is found.
+func readLineAt(f *os.File, offset int64, fileSize int64) []byte {
+	var line []byte
+	buf := make([]byte, probeSize)
+	for offset < fileSize {
+		n, err := f.ReadAt(buf, offset)
+		if err != io.EOF {
+			check(err)
+		}
+		if newlineIndex := bytes.IndexByte(buf[:n], '\n'); newlineIndex >= 0 {
+			return append(line, buf[:newlineIndex+1]...)
+		}
+		line = append(line, buf[:n]...)
+		offset += int64(n)
+	}
+	return line
+}
+
+// lineStartAtOrAfter returns the offset of the first log line that starts
+// at or after offset, or fileSize if there is none.
+func lineStartAtOrAfter(f *os.File, offset int64, fileSize int64) int64 {
+	if offset <= 0 {
+		return 0
+	}
+	return offset - 1 + int64(len(readLineAt(f, offset-1, fileSize)))
+}
+
+// lineStartAtOrBefore returns the offset of the start of the log line that
+// contains offset, looking back no further than minOffset, which has to be
+// the start of a log line.
+func lineStartAtOrBefore(f *os.File, offset int64, minOffset int64) int64 {
+	buf := make([]byte, probeSize)
+	for end := offset; end > minOffset; {
+		start := end - probeSize
+		if start < minOffset {
+			start = minOffset
+		}
+		n, err := f.ReadAt(buf[:end-start], start)
+		if err != io.EOF {
+			check(err)
+		}
+		if newlineIndex := bytes.LastIndexByte(buf[:n], '\n'); newlineIndex >= 0 {
+			return start + int64(newlineIndex) + 1
+		}
+		end = start
+	}
+	return minOffset
+}
+
+// FindOffsetForTime does a binary search over the bytes of the file for
+// the start of the first log line with a timestamp at or after searchTime.
+// Each probe only reads the log lines around one offset, so this takes a
+// logarithmic number of small reads no matter how big the file is and
+// without breaking the file into chunks.
+// Log lines without a timestamp are skipped over when probing.
+// If every timestamp in the file is before searchTime, the size
+// of the file is returned.
+func FindOffsetForTime(f *os.File, searchTime int64) int64 {
+	fileInfo, err := f.Stat()
+	check(err)
+	fileSize := fileInfo.Size()
+
+	low, high := int64(0), fileSize
+	for low < high {
+		mid := low + (high-low)/2
+
+		// Find the first timestamped line at or after mid
+		lineStart := lineStartAtOrAfter(f, mid, fileSize)
+		var lineTimeStamp int64 = -1
+		var line []byte
+		for lineStart < fileSize {
+			line = readLineAt(f, lineStart, fileSize)
+			lineTimeStamp = GetTimeStampFromLine(string(line))
+			if lineTimeStamp > 1 {
+				break
+			}
+			lineStart += int64(len(line))
+		}
+
+		if lineStart >= fileSize || lineTimeStamp >= searchTime {
+			high = mid
+		} else {
+			low = lineStart + int64(len(line))
+		}
+	}
+
+	return lineStartAtOrAfter(f, low, fileSize)
+}
+
+// timeStampRegEx matches the time stamp of a tendermint log line. It is
+// compiled once since every line that is loaded is parsed with it.
+var timeStampRegEx = regexp.MustCompile(`(?P<Year>\d{4})-(?P<Month>\d{2})-(?P<Day>\d{2})\|(?P<Hour>\d{2})\:(?P<Minute>\d{2})\:(?P<Second>\d{2})\.(?P<Millisecond>\d{3})`)
+
 // GetTimeStampFromLine gets the time stamp from the regex
 // This is hardcoded to be like the tendermint Docker logs for now.
 // TODO: add in the functionality to specify how the time stamp is for each
 // each log file.
 func GetTimeStampFromLine(line string) int64 {
-	compRegEx := *regexp.MustCompile(`(?P<Year>\d{4})-(?P<Month>\d{2})-(?P<Day>\d{2})\|(?P<Hour>\d{2})\:(?P<Minute>\d{2})\:(?P<Second>\d{2})\.(?P<Millisecond>\d{3})`)
+	compRegEx := timeStampRegEx
 	match := compRegEx.FindStringSubmatch(line)
 
 	if match == nil {
@@ -545,6 +668,64 @@ func (fc *FileChunk) GetPrevTimestampedFileChunk() *FileChunk {
 	return nil
 }
 
+// GetFileChunkAtOffset returns the log line that contains the file offset,
+// searching forward or backward from this log line. This is used to find
+// a log line again from its offset, like when a saved session is opened.
+// The chunks in between that have not been read yet are skipped over
+// without reading them, so only the part of the file around the offset is
+// read and broken into log lines, no matter how far away it is.
+// If the offset is past the end of the file, the last log line is returned.
+func (fc *FileChunk) GetFileChunkAtOffset(offset int64) *FileChunk {
+	currFileChunk := fc
+	for offset < currFileChunk.FileOffsetStart && currFileChunk.PrevChunk != nil {
+		currFileChunk = currFileChunk.PrevChunk
+	}
+	for offset > currFileChunk.FileOffsetEnd && currFileChunk.NextChunk != nil {
+		currFileChunk = currFileChunk.NextChunk
+	}
+
+	if currFileChunk.FileChunkBytes == nil {
+		currFileChunk = currFileChunk.loadFileChunkAtOffset(offset)
+	}
+
+	// Break the loaded chunk apart until we get to the log line
+	for currFileChunk.LineTimeStamp == -1 {
+		currFileChunk = currFileChunk.SeparateFirstLogLine()
+		if offset <= currFileChunk.FileOffsetEnd || currFileChunk.NextChunk == nil {
+			break
+		}
+		currFileChunk = currFileChunk.NextChunk
+	}
+	return currFileChunk
+}
+
+// loadFileChunkAtOffset reads a chunk that has not been read yet from the
+// start of the log line that contains the offset, and returns that log line.
+// The part of the chunk before the log line is split off and left unread.
+func (fc *FileChunk) loadFileChunkAtOffset(offset int64) *FileChunk {
+	lineStart := lineStartAtOrBefore(fc.FileToRead, offset, fc.FileOffsetStart)
+	if lineStart > fc.FileOffsetStart {
+		restChunk := &FileChunk{
+			FileToRead:      fc.FileToRead,
+			FileChunkBytes:  nil,
+			FileOffsetStart: lineStart,
+			FileOffsetEnd:   fc.FileOffsetEnd,
+			LineTimeStamp:   -1,
+			PrevChunk:       fc,
+			NextChunk:       fc.NextChunk,
+		}
+		if fc.NextChunk != nil {
+			fc.NextChunk.PrevChunk = restChunk
+		}
+		fc.NextChunk = restChunk
+		fc.FileOffsetEnd = lineStart - 1
+		fc = restChunk
+	}
+
+	front, _ := fc.LoadFileChunkForward()
+	return front
+}
+
 // GetFileChunkClosestToTime returns the previous file chunk line just before time.
 // This is used for searching for a particular time in the log file.
 // Note that right now, this just does a brute force linear search.
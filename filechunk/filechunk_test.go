@@ -296,3 +296,71 @@ func ExampleGetTimeStampFromLine() {
 	// Second --> 33
 	// Millisecond --> 68
 }
+
+func ExampleCountLines() {
+	file, err := os.Open("../test_data/medium-logs/node0-json.log")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	head, tail := filechunk.NewFileChunk(file)
+
+	fmt.Println(filechunk.CountLines(file, 0, head.FileOffsetStart))
+	fmt.Println(filechunk.CountLines(file, 0, head.GetNextFileChunk().FileOffsetStart))
+	fmt.Println(filechunk.CountLines(file, 0, tail.FileOffsetStart))
+	fmt.Println(filechunk.CountLines(file, 0, fileInfo.Size()))
+	// Output: 0
+	// 1
+	// 9998
+	// 9998
+}
+
+func ExampleFindOffsetForTime() {
+	file, err := os.Open("../test_data/medium-logs/node0-json.log")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	searchTimes := []string{
+		"2020-05-25|08:45:00.000",
+		"2020-05-25|08:45:44.263",
+		"2020-05-25|08:45:44.264",
+		"2020-05-25|09:00:00.000",
+	}
+
+	for _, searchTime := range searchTimes {
+		offset := filechunk.FindOffsetForTime(file, filechunk.GetTimeStampFromLine(searchTime))
+		fmt.Printf("%v line %v\n", offset, filechunk.CountLines(file, 0, offset)+1)
+	}
+
+	// Output: 0 line 1
+	// 1667650 line 4899
+	// 1668148 line 4901
+	// 3421608 line 9999
+}
+
+func ExampleFileChunk_GetFileChunkAtOffset() {
+	file, err := os.Open("../test_data/medium-logs/node0-json.log")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	head, _ := filechunk.NewFileChunk(file)
+
+	lineChunk := head
+	for _, offset := range []int64{0, 1667651, 1668148, 99999999, 1667650} {
+		lineChunk = lineChunk.GetFileChunkAtOffset(offset)
+		fmt.Printf("%v %v\n", lineChunk.FileOffsetStart, lineChunk.LineTimeStamp > 1)
+	}
+
+	// Output: 0 true
+	// 1667650 true
+	// 1668148 true
+	// 3420961 true
+	// 1667650 true
+}